@@ -0,0 +1,123 @@
+package protokit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pseudomuto/protokit/internal/extensions"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
+)
+
+// CaptureEnvVar is the environment variable used to capture requests for debugging. When it's set to a path,
+// `RunPluginWithIO` (and the other run functions) write the request to that path and the response next to it, with
+// `.response` added before the extension. Paths ending in `.json` are written as JSON, anything else uses the binary
+// format. E.g.
+//
+//	PROTOKIT_CAPTURE=/tmp/req.json protoc --example_out=. booking.proto
+//
+// writes `/tmp/req.json` and `/tmp/req.response.json`. Use `ReplayRequest` to run a plugin against a captured request.
+const CaptureEnvVar = "PROTOKIT_CAPTURE"
+
+// LoadRequest loads a `CodeGeneratorRequest` from a file, e.g. one captured using `CaptureEnvVar`. Files ending in
+// `.json` are parsed as JSON, anything else as binary.
+func LoadRequest(path string) (*pluginpb.CodeGeneratorRequest, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	if isJSON(path) {
+		return unmarshalJSONRequest(data)
+	}
+
+	req := new(pluginpb.CodeGeneratorRequest)
+	if err := proto.Unmarshal(data, req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// unmarshalJSONRequest parses a request captured as JSON. Custom options are resolved using the files in the request
+// (see `extensions.Unmarshal`), so the result matches what protoc sends.
+func unmarshalJSONRequest(data []byte) (*pluginpb.CodeGeneratorRequest, error) {
+	req := new(pluginpb.CodeGeneratorRequest)
+	unmarshal := func(data []byte, m proto.Message, r extensions.TypeResolver) error {
+		return protojson.UnmarshalOptions{Resolver: r, DiscardUnknown: true}.Unmarshal(data, m)
+	}
+
+	if err := extensions.Unmarshal(data, req, unmarshal, (*pluginpb.CodeGeneratorRequest).GetProtoFile); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// ReplayRequest loads a request using `LoadRequest` and runs the plugin against it. The plugin is run the same way as
+// `RunPluginWithIO` does, so errors that would be reported to protoc are set on the response rather than returned.
+func ReplayRequest(p Plugin, path string) (*pluginpb.CodeGeneratorResponse, error) {
+	req, err := LoadRequest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := runRequest(context.Background(), p, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp == nil {
+		resp = new(pluginpb.CodeGeneratorResponse)
+	}
+
+	return resp, nil
+}
+
+// capturePaths returns the paths to capture the request and response to, or empty strings if capturing is disabled
+func capturePaths() (string, string) {
+	path := os.Getenv(CaptureEnvVar)
+	if path == "" {
+		return "", ""
+	}
+
+	ext := filepath.Ext(path)
+	return path, strings.TrimSuffix(path, ext) + ".response" + ext
+}
+
+func writeCapture(path string, msg proto.Message, protos []*descriptorpb.FileDescriptorProto) error {
+	data, err := marshalCapture(path, msg, protos)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o600)
+}
+
+// marshalCapture marshals the message in the format for the path. Custom options that aren't linked into the binary
+// are unknown fields, which JSON can't represent. They're resolved using the extensions declared in protos first.
+func marshalCapture(path string, msg proto.Message, protos []*descriptorpb.FileDescriptorProto) ([]byte, error) {
+	data, err := proto.Marshal(msg)
+	if err != nil || !isJSON(path) {
+		return data, err
+	}
+
+	resolved := msg.ProtoReflect().New().Interface()
+	if err := (proto.UnmarshalOptions{Resolver: extensions.NewResolver(protos)}).Unmarshal(data, resolved); err != nil {
+		return nil, err
+	}
+
+	return protojson.MarshalOptions{Multiline: true}.Marshal(resolved)
+}
+
+func isJSON(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
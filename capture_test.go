@@ -0,0 +1,87 @@
+package protokit_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pseudomuto/protokit"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
+)
+
+// TestCapture sets the environment, so it can't run in parallel
+func TestCapture(t *testing.T) {
+	p := &FuncPlugin{generate: func(r *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+		return &pluginpb.CodeGeneratorResponse{File: []*pluginpb.CodeGeneratorResponse_File{
+			{Name: proto.String("out.txt"), Content: proto.String(r.GetParameter())},
+		}}, nil
+	}}
+
+	req := fixtureRequest(t, "booking.proto")
+	req.Parameter = proto.String("mode=fast")
+
+	tests := []struct {
+		path     string
+		respPath string
+	}{
+		{"capture/req.pb", "capture/req.response.pb"},
+		{"capture/req.json", "capture/req.response.json"},
+		{"capture/req", "capture/req.response"},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		t.Setenv(protokit.CaptureEnvVar, filepath.Join(dir, test.path))
+
+		resp := runPlugin(t, p, req)
+
+		captured, err := protokit.LoadRequest(filepath.Join(dir, test.path))
+		require.NoError(t, err, test.path)
+		require.True(t, proto.Equal(req, captured), test.path)
+
+		data, err := os.ReadFile(filepath.Join(dir, test.respPath))
+		require.NoError(t, err, test.path)
+
+		capturedResp := new(pluginpb.CodeGeneratorResponse)
+		if filepath.Ext(test.path) == ".json" {
+			require.NoError(t, protojson.Unmarshal(data, capturedResp))
+		} else {
+			require.NoError(t, proto.Unmarshal(data, capturedResp))
+		}
+
+		require.True(t, proto.Equal(resp, capturedResp), test.path)
+	}
+}
+
+func TestReplayRequest(t *testing.T) {
+	t.Parallel()
+
+	req := fixtureRequest(t, "booking.proto", "todo.proto")
+	path := filepath.Join(t.TempDir(), "req.pb")
+
+	data, err := proto.Marshal(req)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	p := protokit.NewFilePlugin(protokit.FileGeneratorFunc(
+		func(_ context.Context, fd *protokit.FileDescriptor) ([]*protokit.File, error) {
+			if fd.GetName() == "todo.proto" {
+				return nil, protokit.NewGeneratorError(&protokit.Location{File: fd.GetName()}, "no todos")
+			}
+
+			return []*protokit.File{{Name: proto.String(fd.GetName() + ".txt")}}, nil
+		},
+	))
+	resp, err := protokit.ReplayRequest(p, path)
+	require.NoError(t, err)
+	require.Equal(t, "todo.proto: no todos", resp.GetError())
+	require.Len(t, resp.GetFile(), 1)
+	require.Equal(t, "booking.proto.txt", resp.GetFile()[0].GetName())
+
+	_, err = protokit.ReplayRequest(p, filepath.Join(t.TempDir(), "missing.pb"))
+	require.True(t, os.IsNotExist(err))
+}
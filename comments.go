@@ -2,7 +2,6 @@ package protokit
 
 import (
 	"bytes"
-	"strconv"
 	"strings"
 
 	"google.golang.org/protobuf/types/descriptorpb"
@@ -67,13 +66,7 @@ func ParseComments(fd *descriptorpb.FileDescriptorProto) Comments {
 			continue
 		}
 
-		path := loc.GetPath()
-		key := make([]string, len(path))
-		for idx, p := range path {
-			key[idx] = strconv.Itoa(int(p))
-		}
-
-		comments[strings.Join(key, ".")] = newComment(loc)
+		comments[sourcePathKey(loc.GetPath())] = newComment(loc)
 	}
 
 	return comments
@@ -0,0 +1,220 @@
+// Package compat detects breaking changes between two versions of a schema.
+//
+// Both versions are given as a `FileDescriptorSet` (e.g. built with `protoc --descriptor_set_out --include_imports
+// --include_source_info` or `utils.CompileProtos`). Elements are matched by their fully-qualified names, fields and
+// enum values by their numbers, so moving a message to another file isn't a breaking change. For example:
+//
+//	findings, err := compat.Compare(previous, current, compat.Options{
+//		Categories: []compat.Category{compat.CategoryWire},
+//	})
+//
+//	for _, f := range findings {
+//		// e.g. booking.proto:27:1: error: field 3 ("status") was deleted from "pkg.Booking" without reserving its
+//		// number (FIELD_NO_DELETE)
+//		fmt.Println(f)
+//	}
+//
+// Each rule belongs to a category, which describes what the change breaks. See `Rules` for the full list.
+package compat
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/pseudomuto/protokit"
+	"github.com/pseudomuto/protokit/utils"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Severity describes how likely a finding is to break consumers of the schema
+type Severity int
+
+const (
+	// SeverityError is used for changes that break consumers
+	SeverityError Severity = iota
+	// SeverityWarning is used for changes that only break some consumers (e.g. the ones that don't set a field)
+	SeverityWarning
+)
+
+// String returns the name of the severity, e.g. `error`
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+
+	return "error"
+}
+
+// Category groups rules by what the changes they detect break
+type Category string
+
+const (
+	// CategoryWire rules detect changes that break the binary encoding, i.e. data or peers using the previous version
+	// can't be read correctly
+	CategoryWire Category = "WIRE"
+	// CategoryJSON rules detect changes that only break the JSON encoding, which relies on field and enum value names
+	CategoryJSON Category = "JSON"
+	// CategoryAPI rules detect changes that break generated code and the fully-qualified names used by RPC and `Any`,
+	// e.g. deleted messages or renamed packages
+	CategoryAPI Category = "API"
+)
+
+// A Rule describes one kind of breaking change
+type Rule struct {
+	ID       string
+	Category Category
+	Severity Severity
+}
+
+// Rule IDs
+const (
+	FileNoDelete                        = "FILE_NO_DELETE"
+	FileSamePackage                     = "FILE_SAME_PACKAGE"
+	MessageNoDelete                     = "MESSAGE_NO_DELETE"
+	EnumNoDelete                        = "ENUM_NO_DELETE"
+	ServiceNoDelete                     = "SERVICE_NO_DELETE"
+	MethodNoDelete                      = "METHOD_NO_DELETE"
+	MethodSameInputType                 = "METHOD_SAME_INPUT_TYPE"
+	MethodSameOutputType                = "METHOD_SAME_OUTPUT_TYPE"
+	MethodSameClientStreaming           = "METHOD_SAME_CLIENT_STREAMING"
+	MethodSameServerStreaming           = "METHOD_SAME_SERVER_STREAMING"
+	FieldNoDelete                       = "FIELD_NO_DELETE"
+	FieldNoDeleteUnlessNameReserved     = "FIELD_NO_DELETE_UNLESS_NAME_RESERVED"
+	FieldSameName                       = "FIELD_SAME_NAME"
+	FieldSameJSONName                   = "FIELD_SAME_JSON_NAME"
+	FieldSameType                       = "FIELD_SAME_TYPE"
+	FieldSameJSONType                   = "FIELD_SAME_JSON_TYPE"
+	FieldSameCardinality                = "FIELD_SAME_CARDINALITY"
+	FieldSameRequired                   = "FIELD_SAME_REQUIRED"
+	FieldSameOneof                      = "FIELD_SAME_ONEOF"
+	EnumValueNoDelete                   = "ENUM_VALUE_NO_DELETE"
+	EnumValueNoDeleteUnlessNameReserved = "ENUM_VALUE_NO_DELETE_UNLESS_NAME_RESERVED"
+	EnumValueSameName                   = "ENUM_VALUE_SAME_NAME"
+	ReservedNumberNoDelete              = "RESERVED_NUMBER_NO_DELETE"
+	ReservedNumberNoReuse               = "RESERVED_NUMBER_NO_REUSE"
+	ReservedNameNoDelete                = "RESERVED_NAME_NO_DELETE"
+	ReservedNameNoReuse                 = "RESERVED_NAME_NO_REUSE"
+)
+
+// Rules returns every rule that's checked by `Compare`
+func Rules() []Rule {
+	return []Rule{
+		{ID: FileNoDelete, Category: CategoryAPI, Severity: SeverityError},
+		{ID: FileSamePackage, Category: CategoryAPI, Severity: SeverityError},
+		{ID: MessageNoDelete, Category: CategoryAPI, Severity: SeverityError},
+		{ID: EnumNoDelete, Category: CategoryAPI, Severity: SeverityError},
+		{ID: ServiceNoDelete, Category: CategoryAPI, Severity: SeverityError},
+		{ID: MethodNoDelete, Category: CategoryAPI, Severity: SeverityError},
+		{ID: MethodSameInputType, Category: CategoryWire, Severity: SeverityError},
+		{ID: MethodSameOutputType, Category: CategoryWire, Severity: SeverityError},
+		{ID: MethodSameClientStreaming, Category: CategoryWire, Severity: SeverityError},
+		{ID: MethodSameServerStreaming, Category: CategoryWire, Severity: SeverityError},
+		{ID: FieldNoDelete, Category: CategoryWire, Severity: SeverityError},
+		{ID: FieldNoDeleteUnlessNameReserved, Category: CategoryJSON, Severity: SeverityError},
+		{ID: FieldSameName, Category: CategoryJSON, Severity: SeverityError},
+		{ID: FieldSameJSONName, Category: CategoryJSON, Severity: SeverityError},
+		{ID: FieldSameType, Category: CategoryWire, Severity: SeverityError},
+		{ID: FieldSameJSONType, Category: CategoryJSON, Severity: SeverityError},
+		{ID: FieldSameCardinality, Category: CategoryWire, Severity: SeverityError},
+		{ID: FieldSameRequired, Category: CategoryWire, Severity: SeverityWarning},
+		{ID: FieldSameOneof, Category: CategoryWire, Severity: SeverityError},
+		{ID: EnumValueNoDelete, Category: CategoryWire, Severity: SeverityError},
+		{ID: EnumValueNoDeleteUnlessNameReserved, Category: CategoryJSON, Severity: SeverityError},
+		{ID: EnumValueSameName, Category: CategoryJSON, Severity: SeverityError},
+		{ID: ReservedNumberNoDelete, Category: CategoryWire, Severity: SeverityError},
+		{ID: ReservedNumberNoReuse, Category: CategoryWire, Severity: SeverityError},
+		{ID: ReservedNameNoDelete, Category: CategoryJSON, Severity: SeverityError},
+		{ID: ReservedNameNoReuse, Category: CategoryJSON, Severity: SeverityError},
+	}
+}
+
+// A Finding is a breaking change detected by `Compare`
+type Finding struct {
+	RuleID   string
+	Category Category
+	Severity Severity
+	Message  string
+
+	// Location is where the change was made in the current version. For deleted elements, it's the location of the
+	// closest parent that still exists (e.g. the message a field was deleted from), or the location in the previous
+	// version when there isn't one. The position is unknown if the sets don't include source info.
+	Location *protokit.Location
+}
+
+// String returns the finding formatted as `location: severity: message (RULE_ID)`
+func (f *Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s (%s)", f.Location, f.Severity, f.Message, f.RuleID)
+}
+
+// Options configures `Compare`
+type Options struct {
+	// Categories are the categories of rules to check. All rules are checked when empty.
+	Categories []Category
+}
+
+// Compare reports the breaking changes from previous to current. Findings are grouped by the file in previous they were
+// found in, with files in dependency order (see `protokit.Registry.Files`). An error is returned if either set is
+// invalid (see `protokit.ParseCodeGenRequestE`).
+func Compare(previous, current *descriptorpb.FileDescriptorSet, opts Options) ([]*Finding, error) {
+	prev, err := parse(previous)
+	if err != nil {
+		return nil, fmt.Errorf("previous: %w", err)
+	}
+
+	curr, err := parse(current)
+	if err != nil {
+		return nil, fmt.Errorf("current: %w", err)
+	}
+
+	c := &checker{previous: prev, current: curr, rules: make(map[string]Rule)}
+	for _, rule := range Rules() {
+		if len(opts.Categories) == 0 || slices.Contains(opts.Categories, rule.Category) {
+			c.rules[rule.ID] = rule
+		}
+	}
+
+	c.check()
+	return c.findings, nil
+}
+
+// parse parses all files in the set
+func parse(set *descriptorpb.FileDescriptorSet) (*protokit.Registry, error) {
+	names := make([]string, len(set.GetFile()))
+	for i, f := range set.GetFile() {
+		names[i] = f.GetName()
+	}
+
+	req := utils.CreateGenRequest(set, names...)
+	files, err := protokit.ParseCodeGenRequestE(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return protokit.NewRegistry(req), nil
+	}
+
+	return files[0].GetRegistry(), nil
+}
+
+type checker struct {
+	previous *protokit.Registry
+	current  *protokit.Registry
+	rules    map[string]Rule // enabled rules by ID
+	findings []*Finding
+}
+
+func (c *checker) report(id string, loc *protokit.Location, format string, args ...any) {
+	rule, ok := c.rules[id]
+	if !ok {
+		return
+	}
+
+	c.findings = append(c.findings, &Finding{
+		RuleID:   rule.ID,
+		Category: rule.Category,
+		Severity: rule.Severity,
+		Message:  fmt.Sprintf(format, args...),
+		Location: loc,
+	})
+}
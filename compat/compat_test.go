@@ -0,0 +1,273 @@
+package compat_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pseudomuto/protokit"
+	"github.com/pseudomuto/protokit/compat"
+	"github.com/pseudomuto/protokit/utils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		previous string
+		current  string
+		findings []string
+	}{
+		"no changes": {
+			previous: `message M { string a = 1; }`,
+			current:  `message M { string a = 1; }`,
+		},
+		"compatible changes": {
+			previous: `message M { string a = 1; string b = 2; }`,
+			current: `
+message M {
+  reserved 2;
+  reserved "b";
+  string a = 1;
+  string c = 3;
+}
+message N {}`,
+		},
+		"deleted field": {
+			previous: `message M { string a = 1; string b = 2; }`,
+			current:  `message M { string a = 1; }`,
+			findings: []string{
+				`a.proto:1:20: error: field 2 ("b") was deleted from "M" without reserving its number` +
+					` (FIELD_NO_DELETE)`,
+				`a.proto:1:20: error: field 2 ("b") was deleted from "M" without reserving its name` +
+					` (FIELD_NO_DELETE_UNLESS_NAME_RESERVED)`,
+			},
+		},
+		"renumbered field": {
+			previous: `message M { string a = 1; }`,
+			current:  `message M { string a = 2; }`,
+			findings: []string{
+				`a.proto:1:20: error: field 1 ("a") was deleted from "M" without reserving its number` +
+					` (FIELD_NO_DELETE)`,
+			},
+		},
+		"renamed field": {
+			previous: `message M { string a = 1; string b = 2 [json_name = "x"]; }`,
+			current: `message M {
+  string c = 1;
+  string b = 2;
+}`,
+			findings: []string{
+				`a.proto:2:3: error: field 1 on "M" was renamed from "a" to "c" (FIELD_SAME_NAME)`,
+				`a.proto:3:3: error: JSON name of field "M.b" changed from "x" to "b" (FIELD_SAME_JSON_NAME)`,
+			},
+		},
+		"field types": {
+			previous: `
+message M {
+  int32 a = 1;
+  int32 b = 2;
+  string c = 3;
+  repeated int32 d = 4;
+  oneof o { int32 e = 5; }
+  M f = 6;
+}
+message N {}`,
+			current: `
+message M {
+  int64 a = 1;
+  string b = 2;
+  bytes c = 3;
+  int32 d = 4;
+  int32 e = 5;
+  N f = 6;
+}
+message N {}`,
+			findings: []string{
+				`a.proto:3:3: error: type of field "M.a" changed from int32 to int64 (FIELD_SAME_JSON_TYPE)`,
+				`a.proto:4:3: error: type of field "M.b" changed from int32 to string (FIELD_SAME_TYPE)`,
+				`a.proto:5:3: error: type of field "M.c" changed from string to bytes (FIELD_SAME_JSON_TYPE)`,
+				`a.proto:6:3: error: field "M.d" changed from repeated to singular (FIELD_SAME_CARDINALITY)`,
+				`a.proto:7:3: error: field "M.e" moved from oneof "o" to no oneof (FIELD_SAME_ONEOF)`,
+				`a.proto:8:3: error: type of field "M.f" changed from M to N (FIELD_SAME_TYPE)`,
+			},
+		},
+		"required": {
+			previous: `syntax = "proto2"; message M { required int32 a = 1; optional int32 b = 2; }`,
+			current:  `syntax = "proto2"; message M { optional int32 a = 1; required int32 b = 2; }`,
+			findings: []string{
+				`a.proto:1:32: warning: field "M.a" is no longer required (FIELD_SAME_REQUIRED)`,
+				`a.proto:1:54: warning: field "M.b" is now required (FIELD_SAME_REQUIRED)`,
+			},
+		},
+		"enums": {
+			previous: `enum E { A = 0; B = 1; C = 2; D = 3; reserved 10 to 20; reserved "Z"; } enum F { X = 0; }`,
+			current: `
+enum E {
+  A = 0;
+  BB = 1;
+  reserved 3, 10 to 15;
+  reserved "D";
+  Z = 16;
+}`,
+			findings: []string{
+				`a.proto:4:3: error: enum value 1 on "E" was renamed from "B" to "BB" (ENUM_VALUE_SAME_NAME)`,
+				`a.proto:2:1: error: enum value 2 ("C") was deleted from "E" without reserving its number` +
+					` (ENUM_VALUE_NO_DELETE)`,
+				`a.proto:2:1: error: enum value 2 ("C") was deleted from "E" without reserving its name` +
+					` (ENUM_VALUE_NO_DELETE_UNLESS_NAME_RESERVED)`,
+				`a.proto:7:3: error: enum value "Z" on "E" uses 16, which was reserved (RESERVED_NUMBER_NO_REUSE)`,
+				`a.proto:7:3: error: enum value "Z" on "E" uses a name that was reserved (RESERVED_NAME_NO_REUSE)`,
+				`a.proto:1:1: error: enum "F" was deleted (ENUM_NO_DELETE)`,
+			},
+		},
+		"services": {
+			previous: `
+message A {}
+message B {}
+service S {
+  rpc One(A) returns (B);
+  rpc Two(A) returns (B);
+}
+service T {}`,
+			current: `
+message A {}
+message B {}
+service S {
+  rpc One(B) returns (stream A);
+}`,
+			findings: []string{
+				`a.proto:5:3: error: input type of "S.One" changed from "A" to "B" (METHOD_SAME_INPUT_TYPE)`,
+				`a.proto:5:3: error: output type of "S.One" changed from "B" to "A" (METHOD_SAME_OUTPUT_TYPE)`,
+				`a.proto:5:3: error: server streaming of "S.One" changed from false to true` +
+					` (METHOD_SAME_SERVER_STREAMING)`,
+				`a.proto:4:1: error: method "Two" was deleted from "S" (METHOD_NO_DELETE)`,
+				`a.proto:1:1: error: service "T" was deleted (SERVICE_NO_DELETE)`,
+			},
+		},
+		"reserved": {
+			previous: `message M { reserved 2, 4 to max; reserved "b", "c"; }`,
+			current: `
+message M {
+  reserved 5 to max;
+  string b = 2;
+}`,
+			findings: []string{
+				`a.proto:4:3: error: field "b" on "M" uses 2, which was reserved (RESERVED_NUMBER_NO_REUSE)`,
+				`a.proto:2:1: error: reserved range 4 to max was deleted from "M" (RESERVED_NUMBER_NO_DELETE)`,
+				`a.proto:4:3: error: field "b" on "M" uses a name that was reserved (RESERVED_NAME_NO_REUSE)`,
+				`a.proto:2:1: error: reserved name "c" was deleted from "M" (RESERVED_NAME_NO_DELETE)`,
+			},
+		},
+		"nested": {
+			previous: `message M { message N { message O {} } enum E { A = 0; } }`,
+			current:  `message M {}`,
+			findings: []string{
+				`a.proto:1:20: error: message "M.N" was deleted (MESSAGE_NO_DELETE)`,
+				`a.proto:1:20: error: enum "M.E" was deleted (ENUM_NO_DELETE)`,
+			},
+		},
+		"maps": {
+			previous: `message M { map<string, string> labels = 1; map<string, int32> counts = 2; map<int32, M> refs = 3; }`,
+			current: `
+message M {
+  map<string, string> tags = 1;
+  map<string, int64> counts = 2;
+  map<string, M> refs = 3;
+}`,
+			findings: []string{
+				`a.proto:3:3: error: field 1 on "M" was renamed from "labels" to "tags" (FIELD_SAME_NAME)`,
+				`a.proto:4:3: error: type of field "M.counts" changed from map<string, int32> to map<string, int64>` +
+					` (FIELD_SAME_JSON_TYPE)`,
+				`a.proto:5:3: error: type of field "M.refs" changed from map<int32, M> to map<string, M> (FIELD_SAME_TYPE)`,
+			},
+		},
+		"package": {
+			previous: `package a; message M {}`,
+			current: `
+package b;
+message M {}`,
+			findings: []string{
+				`a.proto:2:1: error: package of "a.proto" changed from "a" to "b" (FILE_SAME_PACKAGE)`,
+				`a.proto:1:1: error: message "a.M" was deleted (MESSAGE_NO_DELETE)`,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			findings, err := compat.Compare(compile(t, test.previous), compile(t, test.current), compat.Options{})
+			require.NoError(t, err)
+
+			var got []string
+			for _, f := range findings {
+				got = append(got, f.String())
+			}
+
+			require.Equal(t, test.findings, got)
+		})
+	}
+}
+
+func TestCompareCategories(t *testing.T) {
+	t.Parallel()
+
+	previous := compile(t, `message M { int32 a = 1; int32 b = 2; }`)
+	current := compile(t, `message M { int64 a = 1; }`)
+
+	findings, err := compat.Compare(previous, current, compat.Options{
+		Categories: []compat.Category{compat.CategoryJSON},
+	})
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+
+	require.Equal(t, compat.FieldSameJSONType, findings[0].RuleID)
+	require.Equal(t, compat.CategoryJSON, findings[0].Category)
+	require.Equal(t, compat.SeverityError, findings[0].Severity)
+	require.Equal(t, "type of field \"M.a\" changed from int32 to int64", findings[0].Message)
+	require.Equal(t, "a.proto", findings[0].Location.File)
+	require.Equal(t, 1, findings[0].Location.StartLine)
+
+	require.Equal(t, compat.FieldNoDeleteUnlessNameReserved, findings[1].RuleID)
+}
+
+func TestCompareInvalidSet(t *testing.T) {
+	t.Parallel()
+
+	invalid := compile(t, `message M { int32 a = 1; }`)
+	invalid.File[0].MessageType[0].Field[0].TypeName = proto.String(".Missing")
+
+	_, err := compat.Compare(invalid, compile(t, `message M {}`), compat.Options{})
+	require.True(t, errors.Is(err, protokit.ErrUnresolvedType))
+	require.ErrorContains(t, err, "previous: a.proto: M.a: unresolved type reference")
+}
+
+func TestRules(t *testing.T) {
+	t.Parallel()
+
+	ids := make(map[string]bool)
+	for _, rule := range compat.Rules() {
+		require.False(t, ids[rule.ID], rule.ID)
+		require.Contains(t, []compat.Category{compat.CategoryWire, compat.CategoryJSON, compat.CategoryAPI}, rule.Category)
+		ids[rule.ID] = true
+	}
+}
+
+// compile compiles the source as a.proto, which is a proto3 file unless the syntax is specified
+func compile(t *testing.T, source string) *descriptorpb.FileDescriptorSet {
+	t.Helper()
+
+	if !strings.Contains(source, "syntax") {
+		source = `syntax = "proto3"; ` + source
+	}
+
+	set, err := utils.CompileProtoSources(map[string]string{"a.proto": source}, "a.proto")
+	require.NoError(t, err)
+
+	return set
+}
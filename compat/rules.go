@@ -0,0 +1,470 @@
+package compat
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pseudomuto/protokit"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// packagePath is the tag number of `package` in FileDescriptorProto
+const packagePath = "2"
+
+// wireTypes groups the field types that use the same encoding, so changing between them doesn't break the binary
+// encoding (at worst values are truncated), but does change how they're encoded as JSON
+var wireTypes = map[descriptorpb.FieldDescriptorProto_Type]int{
+	descriptorpb.FieldDescriptorProto_TYPE_INT32:    1,
+	descriptorpb.FieldDescriptorProto_TYPE_INT64:    1,
+	descriptorpb.FieldDescriptorProto_TYPE_UINT32:   1,
+	descriptorpb.FieldDescriptorProto_TYPE_UINT64:   1,
+	descriptorpb.FieldDescriptorProto_TYPE_BOOL:     1,
+	descriptorpb.FieldDescriptorProto_TYPE_ENUM:     1,
+	descriptorpb.FieldDescriptorProto_TYPE_SINT32:   2,
+	descriptorpb.FieldDescriptorProto_TYPE_SINT64:   2,
+	descriptorpb.FieldDescriptorProto_TYPE_FIXED32:  3,
+	descriptorpb.FieldDescriptorProto_TYPE_SFIXED32: 3,
+	descriptorpb.FieldDescriptorProto_TYPE_FIXED64:  4,
+	descriptorpb.FieldDescriptorProto_TYPE_SFIXED64: 4,
+	descriptorpb.FieldDescriptorProto_TYPE_STRING:   5,
+	descriptorpb.FieldDescriptorProto_TYPE_BYTES:    5,
+}
+
+func (c *checker) check() {
+	for _, prev := range c.previous.Files() {
+		curr := c.current.FindFile(prev.GetName())
+		switch {
+		case curr == nil:
+			c.report(FileNoDelete, prev.GetLocation(), "file %q was deleted", prev.GetName())
+		case prev.GetPackage() != curr.GetPackage():
+			loc := protokit.ParseLocations(curr.FileDescriptorProto)[packagePath]
+			if loc == nil {
+				loc = curr.GetLocation()
+			}
+
+			c.report(FileSamePackage, loc, "package of %q changed from %q to %q",
+				curr.GetName(), prev.GetPackage(), curr.GetPackage())
+		}
+
+		// map entries are checked as part of their map field, since their names follow the field's name
+		for msg := range prev.AllMessages() {
+			if !msg.IsMapEntry() {
+				c.checkMessage(msg)
+			}
+		}
+
+		for enum := range prev.AllEnums() {
+			c.checkEnum(enum)
+		}
+
+		for _, svc := range prev.GetServices() {
+			c.checkService(svc)
+		}
+	}
+}
+
+// deletedLocation returns the location to report for an element that was deleted from the given file and parent
+// message. Nothing is reported (and `nil` returned) when the parent message was deleted too.
+func (c *checker) deletedLocation(
+	file *protokit.FileDescriptor,
+	parent *protokit.Descriptor,
+	fallback *protokit.Location,
+) *protokit.Location {
+	if parent != nil {
+		if m := c.current.FindMessage(parent.GetFullName()); m != nil {
+			return m.GetLocation()
+		}
+
+		return nil
+	}
+
+	if f := c.current.FindFile(file.GetName()); f != nil {
+		return f.GetLocation()
+	}
+
+	return fallback
+}
+
+func (c *checker) checkMessage(prev *protokit.Descriptor) {
+	curr := c.current.FindMessage(prev.GetFullName())
+	if curr == nil {
+		if loc := c.deletedLocation(prev.GetFile(), prev.GetParent(), prev.GetLocation()); loc != nil {
+			c.report(MessageNoDelete, loc, "message %q was deleted", displayName(prev.GetFullName()))
+		}
+
+		return
+	}
+
+	used := make([]usedNumber, len(curr.GetMessageFields()))
+	for i, f := range curr.GetMessageFields() {
+		used[i] = usedNumber{kind: "field", number: int64(f.GetNumber()), name: f.GetName(), loc: f.GetLocation()}
+	}
+
+	for _, pf := range prev.GetMessageFields() {
+		cf := fieldByNumber(curr, pf.GetNumber())
+		if cf == nil {
+			c.checkDeletedField(pf, curr)
+			continue
+		}
+
+		c.checkField(pf, cf)
+	}
+
+	c.checkReservedNumbers(curr, messageRanges(prev), messageRanges(curr), used)
+	c.checkReservedNames(curr, prev.GetReservedName(), curr.GetReservedName(), used)
+}
+
+func (c *checker) checkDeletedField(prev *protokit.FieldDescriptor, curr *protokit.Descriptor) {
+	name := displayName(curr.GetFullName())
+
+	if !isReserved(messageRanges(curr), int64(prev.GetNumber())) {
+		c.report(FieldNoDelete, curr.GetLocation(), "field %d (%q) was deleted from %q without reserving its number",
+			prev.GetNumber(), prev.GetName(), name)
+	}
+
+	if curr.GetMessageField(prev.GetName()) == nil && !slices.Contains(curr.GetReservedName(), prev.GetName()) {
+		c.report(FieldNoDeleteUnlessNameReserved, curr.GetLocation(),
+			"field %d (%q) was deleted from %q without reserving its name", prev.GetNumber(), prev.GetName(), name)
+	}
+}
+
+func (c *checker) checkField(prev, curr *protokit.FieldDescriptor) {
+	name := displayName(curr.GetMessage().GetFullName()) + "." + curr.GetName()
+	loc := curr.GetLocation()
+
+	if prev.GetName() != curr.GetName() {
+		c.report(FieldSameName, loc, "field %d on %q was renamed from %q to %q",
+			curr.GetNumber(), displayName(curr.GetMessage().GetFullName()), prev.GetName(), curr.GetName())
+	} else if jsonName(prev) != jsonName(curr) {
+		c.report(FieldSameJSONName, loc, "JSON name of field %q changed from %q to %q", name, jsonName(prev),
+			jsonName(curr))
+	}
+
+	if !sameType(prev, curr) {
+		rule := FieldSameType
+		if sameWireType(prev, curr) {
+			rule = FieldSameJSONType
+		}
+
+		c.report(rule, loc, "type of field %q changed from %s to %s", name, fieldType(prev), fieldType(curr))
+	}
+
+	switch {
+	case isRepeated(prev) != isRepeated(curr):
+		c.report(FieldSameCardinality, loc, "field %q changed from %s to %s", name, cardinality(prev), cardinality(curr))
+	case isRequired(prev) && !isRequired(curr):
+		c.report(FieldSameRequired, loc, "field %q is no longer required", name)
+	case !isRequired(prev) && isRequired(curr):
+		c.report(FieldSameRequired, loc, "field %q is now required", name)
+	}
+
+	if oneofName(prev) != oneofName(curr) {
+		c.report(FieldSameOneof, loc, "field %q moved from %s to %s", name, oneofName(prev), oneofName(curr))
+	}
+}
+
+func (c *checker) checkEnum(prev *protokit.EnumDescriptor) {
+	curr := c.current.FindEnum(prev.GetFullName())
+	if curr == nil {
+		if loc := c.deletedLocation(prev.GetFile(), prev.GetParent(), prev.GetLocation()); loc != nil {
+			c.report(EnumNoDelete, loc, "enum %q was deleted", displayName(prev.GetFullName()))
+		}
+
+		return
+	}
+
+	name := displayName(curr.GetFullName())
+	used := make([]usedNumber, len(curr.GetValues()))
+	for i, v := range curr.GetValues() {
+		used[i] = usedNumber{kind: "enum value", number: int64(v.GetNumber()), name: v.GetName(), loc: v.GetLocation()}
+	}
+
+	for _, pv := range prev.GetValues() {
+		values := slices.DeleteFunc(slices.Clone(curr.GetValues()), func(v *protokit.EnumValueDescriptor) bool {
+			return v.GetNumber() != pv.GetNumber()
+		})
+
+		if len(values) > 0 {
+			if !slices.ContainsFunc(values, func(v *protokit.EnumValueDescriptor) bool { return v.GetName() == pv.GetName() }) {
+				c.report(EnumValueSameName, values[0].GetLocation(), "enum value %d on %q was renamed from %q to %q",
+					pv.GetNumber(), name, pv.GetName(), values[0].GetName())
+			}
+
+			continue
+		}
+
+		if !isReserved(enumRanges(curr), int64(pv.GetNumber())) {
+			c.report(EnumValueNoDelete, curr.GetLocation(),
+				"enum value %d (%q) was deleted from %q without reserving its number", pv.GetNumber(), pv.GetName(), name)
+		}
+
+		if curr.GetNamedValue(pv.GetName()) == nil && !slices.Contains(curr.GetReservedName(), pv.GetName()) {
+			c.report(EnumValueNoDeleteUnlessNameReserved, curr.GetLocation(),
+				"enum value %d (%q) was deleted from %q without reserving its name", pv.GetNumber(), pv.GetName(), name)
+		}
+	}
+
+	c.checkReservedNumbers(curr, enumRanges(prev), enumRanges(curr), used)
+	c.checkReservedNames(curr, prev.GetReservedName(), curr.GetReservedName(), used)
+}
+
+func (c *checker) checkService(prev *protokit.ServiceDescriptor) {
+	curr := c.current.FindService(prev.GetFullName())
+	if curr == nil {
+		c.report(ServiceNoDelete, c.deletedLocation(prev.GetFile(), nil, prev.GetLocation()), "service %q was deleted",
+			displayName(prev.GetFullName()))
+
+		return
+	}
+
+	for _, pm := range prev.GetMethods() {
+		cm := curr.GetNamedMethod(pm.GetName())
+		if cm == nil {
+			c.report(MethodNoDelete, curr.GetLocation(), "method %q was deleted from %q", pm.GetName(),
+				displayName(curr.GetFullName()))
+
+			continue
+		}
+
+		name := displayName(cm.GetFullName())
+		loc := cm.GetLocation()
+
+		if pm.GetInputType() != cm.GetInputType() {
+			c.report(MethodSameInputType, loc, "input type of %q changed from %q to %q", name,
+				displayName(pm.GetInputType()), displayName(cm.GetInputType()))
+		}
+
+		if pm.GetOutputType() != cm.GetOutputType() {
+			c.report(MethodSameOutputType, loc, "output type of %q changed from %q to %q", name,
+				displayName(pm.GetOutputType()), displayName(cm.GetOutputType()))
+		}
+
+		if pm.GetClientStreaming() != cm.GetClientStreaming() {
+			c.report(MethodSameClientStreaming, loc, "client streaming of %q changed from %t to %t", name,
+				pm.GetClientStreaming(), cm.GetClientStreaming())
+		}
+
+		if pm.GetServerStreaming() != cm.GetServerStreaming() {
+			c.report(MethodSameServerStreaming, loc, "server streaming of %q changed from %t to %t", name,
+				pm.GetServerStreaming(), cm.GetServerStreaming())
+		}
+	}
+}
+
+// A reservable is a message or enum, which can reserve field or value numbers and names
+type reservable interface {
+	GetFullName() string
+	GetLocation() *protokit.Location
+}
+
+// usedNumber is a field or enum value in the current version
+type usedNumber struct {
+	kind   string
+	number int64
+	name   string
+	loc    *protokit.Location
+}
+
+// numberRange is a range of reserved numbers. Unlike enum reserved ranges, end is exclusive.
+type numberRange struct {
+	start int64
+	end   int64
+}
+
+// String returns the range as it's written in a .proto file, e.g. `5 to 10`
+func (r numberRange) String() string {
+	switch {
+	case r.end-r.start == 1:
+		return strconv.FormatInt(r.start, 10)
+	case r.end-1 == math.MaxInt32 || r.end-1 == maxFieldNumber:
+		return fmt.Sprintf("%d to max", r.start)
+	default:
+		return fmt.Sprintf("%d to %d", r.start, r.end-1)
+	}
+}
+
+// maxFieldNumber is the highest field number allowed by protobuf
+const maxFieldNumber = 1<<29 - 1
+
+// checkReservedNumbers reports the numbers that were reserved in the previous version and are now used, or aren't
+// reserved anymore
+func (c *checker) checkReservedNumbers(owner reservable, prev, curr []numberRange, used []usedNumber) {
+	for _, r := range prev {
+		reused := false
+		for _, u := range used {
+			if r.start <= u.number && u.number < r.end {
+				c.report(ReservedNumberNoReuse, u.loc, "%s %q on %q uses %d, which was reserved", u.kind, u.name,
+					displayName(owner.GetFullName()), u.number)
+
+				reused = true
+			}
+		}
+
+		if !reused && !covers(curr, r) {
+			c.report(ReservedNumberNoDelete, owner.GetLocation(), "reserved range %s was deleted from %q", r,
+				displayName(owner.GetFullName()))
+		}
+	}
+}
+
+// checkReservedNames reports the names that were reserved in the previous version and are now used, or aren't
+// reserved anymore
+func (c *checker) checkReservedNames(owner reservable, prev, curr []string, used []usedNumber) {
+	for _, name := range prev {
+		if i := slices.IndexFunc(used, func(u usedNumber) bool { return u.name == name }); i >= 0 {
+			c.report(ReservedNameNoReuse, used[i].loc, "%s %q on %q uses a name that was reserved", used[i].kind, name,
+				displayName(owner.GetFullName()))
+
+			continue
+		}
+
+		if !slices.Contains(curr, name) {
+			c.report(ReservedNameNoDelete, owner.GetLocation(), "reserved name %q was deleted from %q", name,
+				displayName(owner.GetFullName()))
+		}
+	}
+}
+
+func messageRanges(m *protokit.Descriptor) []numberRange {
+	ranges := make([]numberRange, len(m.GetReservedRange()))
+	for i, r := range m.GetReservedRange() {
+		ranges[i] = numberRange{start: int64(r.GetStart()), end: int64(r.GetEnd())}
+	}
+
+	return ranges
+}
+
+func enumRanges(e *protokit.EnumDescriptor) []numberRange {
+	ranges := make([]numberRange, len(e.GetReservedRange()))
+	for i, r := range e.GetReservedRange() {
+		ranges[i] = numberRange{start: int64(r.GetStart()), end: int64(r.GetEnd()) + 1}
+	}
+
+	return ranges
+}
+
+func isReserved(ranges []numberRange, number int64) bool {
+	return covers(ranges, numberRange{start: number, end: number + 1})
+}
+
+// covers returns whether every number in r is in one of the ranges
+func covers(ranges []numberRange, r numberRange) bool {
+	ranges = slices.Clone(ranges)
+	slices.SortFunc(ranges, func(a, b numberRange) int { return cmp.Compare(a.start, b.start) })
+
+	next := r.start
+	for _, rng := range ranges {
+		if rng.start <= next && next < rng.end {
+			next = rng.end
+		}
+	}
+
+	return next >= r.end
+}
+
+func fieldByNumber(m *protokit.Descriptor, number int32) *protokit.FieldDescriptor {
+	for _, f := range m.GetMessageFields() {
+		if f.GetNumber() == number {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// jsonName returns the field's JSON name, which protoc always sets. Otherwise, it's derived from the field name.
+func jsonName(f *protokit.FieldDescriptor) string {
+	if f.JsonName != nil {
+		return f.GetJsonName()
+	}
+
+	var b strings.Builder
+	upper := false
+	for _, r := range f.GetName() {
+		if r == '_' {
+			upper = true
+			continue
+		}
+
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// sameType returns whether the fields have the same type. Map fields are compared by their key and value types rather
+// than by the name of their entry message, which changes whenever the field is renamed.
+func sameType(prev, curr *protokit.FieldDescriptor) bool {
+	if prev.IsMap() && curr.IsMap() {
+		return sameType(prev.GetMapKey(), curr.GetMapKey()) && sameType(prev.GetMapValue(), curr.GetMapValue())
+	}
+
+	return prev.GetType() == curr.GetType() && prev.GetTypeName() == curr.GetTypeName()
+}
+
+// sameWireType returns whether the fields' types use the same encoding (see `wireTypes`)
+func sameWireType(prev, curr *protokit.FieldDescriptor) bool {
+	if prev.IsMap() && curr.IsMap() {
+		return sameWireType(prev.GetMapKey(), curr.GetMapKey()) && sameWireType(prev.GetMapValue(), curr.GetMapValue())
+	}
+
+	if sameType(prev, curr) {
+		return true
+	}
+
+	group, ok := wireTypes[prev.GetType()]
+	return ok && group == wireTypes[curr.GetType()]
+}
+
+// fieldType returns the type of the field as it's written in a .proto file
+func fieldType(f *protokit.FieldDescriptor) string {
+	if f.IsMap() {
+		return fmt.Sprintf("map<%s, %s>", fieldType(f.GetMapKey()), fieldType(f.GetMapValue()))
+	}
+
+	if f.GetTypeName() != "" {
+		return displayName(f.GetTypeName())
+	}
+
+	return strings.ToLower(strings.TrimPrefix(f.GetType().String(), "TYPE_"))
+}
+
+func isRepeated(f *protokit.FieldDescriptor) bool {
+	return f.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+}
+
+func isRequired(f *protokit.FieldDescriptor) bool {
+	return f.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REQUIRED ||
+		f.GetResolvedFeatures().GetFieldPresence() == descriptorpb.FeatureSet_LEGACY_REQUIRED
+}
+
+func cardinality(f *protokit.FieldDescriptor) string {
+	switch {
+	case isRepeated(f):
+		return "repeated"
+	default:
+		return "singular"
+	}
+}
+
+// oneofName describes the (non-synthetic) oneof the field belongs to
+func oneofName(f *protokit.FieldDescriptor) string {
+	if o := f.GetOneof(); o != nil && !o.IsSynthetic() {
+		return fmt.Sprintf("oneof %q", o.GetName())
+	}
+
+	return "no oneof"
+}
+
+// displayName returns the fully-qualified name without the leading dot
+func displayName(name string) string { return strings.TrimPrefix(name, ".") }
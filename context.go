@@ -2,6 +2,9 @@ package protokit
 
 import (
 	"context"
+
+	"github.com/pseudomuto/protokit/internal/extensions"
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
 )
 
 type contextKey string
@@ -11,6 +14,11 @@ const (
 	descriptorContextKey = contextKey("descriptor")
 	enumContextKey       = contextKey("enum")
 	serviceContextKey    = contextKey("service")
+	requestContextKey    = contextKey("request")
+	paramsContextKey     = contextKey("params")
+	registryContextKey   = contextKey("registry")
+
+	extensionResolverContextKey = contextKey("extensionResolver")
 )
 
 // ContextWithFileDescriptor returns a new context with the attached `FileDescriptor`
@@ -56,3 +64,45 @@ func ServiceDescriptorFromContext(ctx context.Context) (*ServiceDescriptor, bool
 	val, ok := ctx.Value(serviceContextKey).(*ServiceDescriptor)
 	return val, ok
 }
+
+// ContextWithRequest returns a new context with the attached `CodeGeneratorRequest`
+func ContextWithRequest(ctx context.Context, req *pluginpb.CodeGeneratorRequest) context.Context {
+	return context.WithValue(ctx, requestContextKey, req)
+}
+
+// RequestFromContext returns the `CodeGeneratorRequest` from the context and whether or not the key was found
+func RequestFromContext(ctx context.Context) (*pluginpb.CodeGeneratorRequest, bool) {
+	val, ok := ctx.Value(requestContextKey).(*pluginpb.CodeGeneratorRequest)
+	return val, ok
+}
+
+// ContextWithRegistry returns a new context with the attached `Registry`
+func ContextWithRegistry(ctx context.Context, reg *Registry) context.Context {
+	return context.WithValue(ctx, registryContextKey, reg)
+}
+
+// RegistryFromContext returns the `Registry` from the context and whether or not the key was found
+func RegistryFromContext(ctx context.Context) (*Registry, bool) {
+	val, ok := ctx.Value(registryContextKey).(*Registry)
+	return val, ok
+}
+
+// ContextWithParams returns a new context with the attached plugin parameters
+func ContextWithParams(ctx context.Context, params Params) context.Context {
+	return context.WithValue(ctx, paramsContextKey, params)
+}
+
+// ParamsFromContext returns the plugin parameters from the context and whether or not the key was found
+func ParamsFromContext(ctx context.Context) (Params, bool) {
+	val, ok := ctx.Value(paramsContextKey).(Params)
+	return val, ok
+}
+
+func contextWithExtensionResolver(ctx context.Context, r *extensions.Resolver) context.Context {
+	return context.WithValue(ctx, extensionResolverContextKey, r)
+}
+
+func extensionResolverFromContext(ctx context.Context) (*extensions.Resolver, bool) {
+	val, ok := ctx.Value(extensionResolverContextKey).(*extensions.Resolver)
+	return val, ok
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/pseudomuto/protokit"
 	"github.com/stretchr/testify/require"
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
 )
 
 func TestContextWithFileDescriptor(t *testing.T) {
@@ -67,3 +68,48 @@ func TestContextWithServiceDescriptor(t *testing.T) {
 	require.NotNil(t, val)
 	require.True(t, found)
 }
+
+func TestContextWithRequest(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	val, found := protokit.RequestFromContext(ctx)
+	require.Nil(t, val)
+	require.False(t, found)
+
+	ctx = protokit.ContextWithRequest(ctx, new(pluginpb.CodeGeneratorRequest))
+	val, found = protokit.RequestFromContext(ctx)
+	require.NotNil(t, val)
+	require.True(t, found)
+}
+
+func TestContextWithRegistry(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	val, found := protokit.RegistryFromContext(ctx)
+	require.Nil(t, val)
+	require.False(t, found)
+
+	ctx = protokit.ContextWithRegistry(ctx, protokit.NewRegistry(new(pluginpb.CodeGeneratorRequest)))
+	val, found = protokit.RegistryFromContext(ctx)
+	require.NotNil(t, val)
+	require.True(t, found)
+}
+
+func TestContextWithParams(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	val, found := protokit.ParamsFromContext(ctx)
+	require.Nil(t, val)
+	require.False(t, found)
+
+	ctx = protokit.ContextWithParams(ctx, protokit.Params{{Key: "a", Value: "b"}})
+	val, found = protokit.ParamsFromContext(ctx)
+	require.Equal(t, "b", val.Get("a"))
+	require.True(t, found)
+}
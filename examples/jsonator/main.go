@@ -1,15 +1,13 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
 
-	"google.golang.org/protobuf/proto"
-	pluginpb "google.golang.org/protobuf/types/pluginpb"
 	"github.com/pseudomuto/protokit"
 	"google.golang.org/genproto/googleapis/api/annotations"
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
 )
 
 func main() {
@@ -28,21 +26,20 @@ func (p *plugin) Generate(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGen
 		files[i] = newFile(d)
 	}
 
-	buf := new(bytes.Buffer)
-	enc := json.NewEncoder(buf)
+	b := protokit.NewResponseBuilder()
+	out, err := b.Create("output.json")
+	if err != nil {
+		return nil, err
+	}
+
+	enc := json.NewEncoder(out)
 	enc.SetIndent("", "  ")
 
 	if err := enc.Encode(files); err != nil {
 		return nil, err
 	}
 
-	resp := new(pluginpb.CodeGeneratorResponse)
-	resp.File = append(resp.File, &pluginpb.CodeGeneratorResponse_File{
-		Name:    proto.String("output.json"),
-		Content: proto.String(buf.String()),
-	})
-
-	return resp, nil
+	return b.Response(), nil
 }
 
 type file struct {
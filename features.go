@@ -0,0 +1,170 @@
+package protokit
+
+import (
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// defaultFeatures caches the default `FeatureSet` for each edition
+var defaultFeatures sync.Map
+
+// editionDefaults returns the default features for the given edition. The defaults are taken from the
+// `edition_defaults` option of each field in `google.protobuf.FeatureSet`, using the entry for the latest edition that
+// isn't newer than the requested one.
+//
+// The returned value is shared and must not be modified.
+func editionDefaults(edition descriptorpb.Edition) *descriptorpb.FeatureSet {
+	if fs, ok := defaultFeatures.Load(edition); ok {
+		return fs.(*descriptorpb.FeatureSet)
+	}
+
+	defaults := new(descriptorpb.FeatureSet)
+	msg := defaults.ProtoReflect()
+	fields := msg.Descriptor().Fields()
+
+	for i := range fields.Len() {
+		fd := fields.Get(i)
+		if fd.Enum() == nil {
+			continue
+		}
+
+		opts, _ := fd.Options().(*descriptorpb.FieldOptions)
+		var value *descriptorpb.FieldOptions_EditionDefault
+		for _, d := range opts.GetEditionDefaults() {
+			if d.GetEdition() <= edition && (value == nil || d.GetEdition() > value.GetEdition()) {
+				value = d
+			}
+		}
+
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(value.GetValue())); ev != nil {
+			msg.Set(fd, protoreflect.ValueOfEnum(ev.Number()))
+		}
+	}
+
+	fs, _ := defaultFeatures.LoadOrStore(edition, defaults)
+	return fs.(*descriptorpb.FeatureSet)
+}
+
+// mergeFeatures returns the parent features overridden by any features set in child. When child doesn't set anything,
+// the parent is returned as is.
+func mergeFeatures(parent, child *descriptorpb.FeatureSet) *descriptorpb.FeatureSet {
+	if child == nil || proto.Size(child) == 0 {
+		return parent
+	}
+
+	resolved := proto.CloneOf(parent)
+	proto.Merge(resolved, child)
+
+	return resolved
+}
+
+// fileEdition returns the edition of the file, mapping the proto2 and proto3 syntaxes to their respective editions
+func fileEdition(f *FileDescriptor) descriptorpb.Edition {
+	switch {
+	case f.IsEditions():
+		return f.GetEdition()
+	case f.GetSyntax() == "proto3":
+		return descriptorpb.Edition_EDITION_PROTO3
+	default:
+		return descriptorpb.Edition_EDITION_PROTO2
+	}
+}
+
+// legacyFieldFeatures returns the features implied by proto2/proto3 field declarations. Editions express these using
+// features instead (e.g. `features.field_presence = LEGACY_REQUIRED` rather than `required`).
+func legacyFieldFeatures(fd *descriptorpb.FieldDescriptorProto) *descriptorpb.FeatureSet {
+	fs := new(descriptorpb.FeatureSet)
+
+	if fd.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REQUIRED {
+		fs.FieldPresence = descriptorpb.FeatureSet_LEGACY_REQUIRED.Enum()
+	}
+
+	if fd.GetProto3Optional() {
+		fs.FieldPresence = descriptorpb.FeatureSet_EXPLICIT.Enum()
+	}
+
+	if fd.GetType() == descriptorpb.FieldDescriptorProto_TYPE_GROUP {
+		fs.MessageEncoding = descriptorpb.FeatureSet_DELIMITED.Enum()
+	}
+
+	if fd.GetOptions() != nil && fd.GetOptions().Packed != nil {
+		fs.RepeatedFieldEncoding = descriptorpb.FeatureSet_EXPANDED.Enum()
+		if fd.GetOptions().GetPacked() {
+			fs.RepeatedFieldEncoding = descriptorpb.FeatureSet_PACKED.Enum()
+		}
+	}
+
+	return fs
+}
+
+// resolveFeatures computes the resolved features of every element in the file. Each element inherits the features of
+// its parent (file => message => oneof => field, and so on) and overrides them with its own `features` option.
+func resolveFeatures(f *FileDescriptor) {
+	f.ResolvedFeatures = mergeFeatures(editionDefaults(fileEdition(f)), f.GetOptions().GetFeatures())
+
+	resolveEnumFeatures(f.ResolvedFeatures, f.GetEnums())
+	resolveExtensionFeatures(f, f.ResolvedFeatures, f.GetExtensions())
+	resolveMessageFeatures(f, f.ResolvedFeatures, f.GetMessages())
+
+	for _, svc := range f.GetServices() {
+		svc.ResolvedFeatures = mergeFeatures(f.ResolvedFeatures, svc.GetOptions().GetFeatures())
+
+		for _, m := range svc.GetMethods() {
+			m.ResolvedFeatures = mergeFeatures(svc.ResolvedFeatures, m.GetOptions().GetFeatures())
+		}
+	}
+}
+
+func resolveEnumFeatures(parent *descriptorpb.FeatureSet, enums []*EnumDescriptor) {
+	for _, e := range enums {
+		e.ResolvedFeatures = mergeFeatures(parent, e.GetOptions().GetFeatures())
+
+		for _, v := range e.GetValues() {
+			v.ResolvedFeatures = mergeFeatures(e.ResolvedFeatures, v.GetOptions().GetFeatures())
+		}
+	}
+}
+
+func resolveExtensionFeatures(f *FileDescriptor, parent *descriptorpb.FeatureSet, exts []*ExtensionDescriptor) {
+	for _, ext := range exts {
+		ext.ResolvedFeatures = resolveFieldFeatures(f, parent, ext.FieldDescriptorProto)
+	}
+}
+
+func resolveMessageFeatures(f *FileDescriptor, parent *descriptorpb.FeatureSet, msgs []*Descriptor) {
+	for _, m := range msgs {
+		m.ResolvedFeatures = mergeFeatures(parent, m.GetOptions().GetFeatures())
+
+		for _, o := range m.GetOneofs() {
+			o.ResolvedFeatures = mergeFeatures(m.ResolvedFeatures, o.GetOptions().GetFeatures())
+		}
+
+		for _, field := range m.GetMessageFields() {
+			fieldParent := m.ResolvedFeatures
+			if field.Oneof != nil {
+				fieldParent = field.Oneof.ResolvedFeatures
+			}
+
+			field.ResolvedFeatures = resolveFieldFeatures(f, fieldParent, field.FieldDescriptorProto)
+		}
+
+		resolveEnumFeatures(m.ResolvedFeatures, m.GetEnums())
+		resolveExtensionFeatures(f, m.ResolvedFeatures, m.GetExtensions())
+		resolveMessageFeatures(f, m.ResolvedFeatures, m.GetMessages())
+	}
+}
+
+func resolveFieldFeatures(
+	f *FileDescriptor,
+	parent *descriptorpb.FeatureSet,
+	fd *descriptorpb.FieldDescriptorProto,
+) *descriptorpb.FeatureSet {
+	if !f.IsEditions() {
+		parent = mergeFeatures(parent, legacyFieldFeatures(fd))
+	}
+
+	return mergeFeatures(parent, fd.GetOptions().GetFeatures())
+}
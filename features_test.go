@@ -0,0 +1,178 @@
+package protokit_test
+
+import (
+	"testing"
+
+	"github.com/pseudomuto/protokit"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestResolvedFeaturesProto2(t *testing.T) {
+	t.Parallel()
+
+	proto2, _ := setupParserTest(t)
+
+	fs := proto2.GetResolvedFeatures()
+	require.Equal(t, descriptorpb.FeatureSet_EXPLICIT, fs.GetFieldPresence())
+	require.Equal(t, descriptorpb.FeatureSet_CLOSED, fs.GetEnumType())
+	require.Equal(t, descriptorpb.FeatureSet_EXPANDED, fs.GetRepeatedFieldEncoding())
+	require.Equal(t, descriptorpb.FeatureSet_NONE, fs.GetUtf8Validation())
+	require.Equal(t, descriptorpb.FeatureSet_LENGTH_PREFIXED, fs.GetMessageEncoding())
+	require.Equal(t, descriptorpb.FeatureSet_LEGACY_BEST_EFFORT, fs.GetJsonFormat())
+
+	booking := proto2.GetMessage("Booking")
+	require.Equal(t, fs, booking.GetResolvedFeatures())
+	require.Equal(t, fs, proto2.GetEnum("BookingType").GetResolvedFeatures())
+	require.Equal(t, fs, proto2.GetService("BookingService").GetNamedMethod("BookVehicle").GetResolvedFeatures())
+
+	// required fields have legacy required presence
+	required := booking.GetMessageField("vehicle_id")
+	require.Equal(t, descriptorpb.FeatureSet_LEGACY_REQUIRED, required.GetResolvedFeatures().GetFieldPresence())
+	require.True(t, required.HasPresence())
+
+	optional := booking.GetMessageField("payment_received")
+	require.Equal(t, descriptorpb.FeatureSet_EXPLICIT, optional.GetResolvedFeatures().GetFieldPresence())
+	require.True(t, optional.HasPresence())
+}
+
+func TestResolvedFeaturesProto3(t *testing.T) {
+	t.Parallel()
+
+	_, proto3 := setupParserTest(t)
+
+	fs := proto3.GetResolvedFeatures()
+	require.False(t, proto3.HasExplicitFieldPresence())
+	require.Equal(t, descriptorpb.FeatureSet_IMPLICIT, fs.GetFieldPresence())
+	require.Equal(t, descriptorpb.FeatureSet_OPEN, fs.GetEnumType())
+	require.Equal(t, descriptorpb.FeatureSet_PACKED, fs.GetRepeatedFieldEncoding())
+	require.Equal(t, descriptorpb.FeatureSet_VERIFY, fs.GetUtf8Validation())
+	require.Equal(t, descriptorpb.FeatureSet_ALLOW, fs.GetJsonFormat())
+
+	list := proto3.GetMessage("List")
+	require.False(t, list.GetMessageField("name").HasPresence())
+	require.True(t, list.GetMessageField("created_at").HasPresence()) // message fields always have presence
+	require.Equal(t, fs, proto3.GetMessage("Item").GetEnum("Status").GetValues()[0].GetResolvedFeatures())
+}
+
+func TestResolvedFeaturesEditions(t *testing.T) {
+	t.Parallel()
+
+	req := fixtureRequest(t, "edition2023.proto", "edition2024.proto", "edition2023_implicit.proto")
+	files := protokit.ParseCodeGenRequest(req)
+
+	for _, f := range files[:2] {
+		fs := f.GetResolvedFeatures()
+		require.Equal(t, descriptorpb.FeatureSet_EXPLICIT, fs.GetFieldPresence())
+		require.Equal(t, descriptorpb.FeatureSet_OPEN, fs.GetEnumType())
+		require.Equal(t, descriptorpb.FeatureSet_PACKED, fs.GetRepeatedFieldEncoding())
+		require.Equal(t, descriptorpb.FeatureSet_VERIFY, fs.GetUtf8Validation())
+		require.Equal(t, descriptorpb.FeatureSet_LENGTH_PREFIXED, fs.GetMessageEncoding())
+		require.Equal(t, descriptorpb.FeatureSet_ALLOW, fs.GetJsonFormat())
+	}
+
+	require.Equal(t, descriptorpb.FeatureSet_STYLE_LEGACY, files[0].GetResolvedFeatures().GetEnforceNamingStyle())
+	require.Equal(t, descriptorpb.FeatureSet_STYLE2024, files[1].GetResolvedFeatures().GetEnforceNamingStyle())
+
+	implicit := files[2]
+	require.Equal(t, descriptorpb.FeatureSet_IMPLICIT, implicit.GetResolvedFeatures().GetFieldPresence())
+
+	field := implicit.GetMessage("TestMessage").GetMessageField("id")
+	require.Equal(t, descriptorpb.FeatureSet_IMPLICIT, field.GetResolvedFeatures().GetFieldPresence())
+	require.False(t, field.HasPresence())
+}
+
+func TestResolvedFeaturesInheritance(t *testing.T) {
+	t.Parallel()
+
+	field := func(name string, number int32, features *descriptorpb.FeatureSet) *descriptorpb.FieldDescriptorProto {
+		fd := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+		}
+		if features != nil {
+			fd.Options = &descriptorpb.FieldOptions{Features: features}
+		}
+
+		return fd
+	}
+
+	oneofField := field("choice", 4, nil)
+	oneofField.OneofIndex = proto.Int32(0)
+
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"features.proto"},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{{
+			Name:    proto.String("features.proto"),
+			Package: proto.String("acme.features"),
+			Syntax:  proto.String("editions"),
+			Edition: descriptorpb.Edition_EDITION_2023.Enum(),
+			Options: &descriptorpb.FileOptions{
+				Features: &descriptorpb.FeatureSet{FieldPresence: descriptorpb.FeatureSet_IMPLICIT.Enum()},
+			},
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("Thing"),
+				Options: &descriptorpb.MessageOptions{
+					Features: &descriptorpb.FeatureSet{Utf8Validation: descriptorpb.FeatureSet_NONE.Enum()},
+				},
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("plain", 1, nil),
+					field("explicit", 2, &descriptorpb.FeatureSet{FieldPresence: descriptorpb.FeatureSet_EXPLICIT.Enum()}),
+					field("checked", 3, &descriptorpb.FeatureSet{Utf8Validation: descriptorpb.FeatureSet_VERIFY.Enum()}),
+					oneofField,
+				},
+				OneofDecl: []*descriptorpb.OneofDescriptorProto{{
+					Name: proto.String("kind"),
+					Options: &descriptorpb.OneofOptions{
+						Features: &descriptorpb.FeatureSet{JsonFormat: descriptorpb.FeatureSet_LEGACY_BEST_EFFORT.Enum()},
+					},
+				}},
+				EnumType: []*descriptorpb.EnumDescriptorProto{{
+					Name: proto.String("Kind"),
+					Options: &descriptorpb.EnumOptions{
+						Features: &descriptorpb.FeatureSet{EnumType: descriptorpb.FeatureSet_CLOSED.Enum()},
+					},
+					Value: []*descriptorpb.EnumValueDescriptorProto{{Name: proto.String("KIND_A"), Number: proto.Int32(0)}},
+				}},
+			}},
+		}},
+	}
+
+	file := protokit.ParseCodeGenRequest(req)[0]
+	require.False(t, file.HasExplicitFieldPresence())
+
+	msg := file.GetMessage("Thing")
+	require.Equal(t, descriptorpb.FeatureSet_IMPLICIT, msg.GetResolvedFeatures().GetFieldPresence())
+	require.Equal(t, descriptorpb.FeatureSet_NONE, msg.GetResolvedFeatures().GetUtf8Validation())
+
+	plain := msg.GetMessageField("plain")
+	require.Equal(t, descriptorpb.FeatureSet_NONE, plain.GetResolvedFeatures().GetUtf8Validation())
+	require.False(t, plain.HasPresence())
+
+	// the file says implicit, but the field overrides it
+	explicit := msg.GetMessageField("explicit")
+	require.Equal(t, descriptorpb.FeatureSet_EXPLICIT, explicit.GetResolvedFeatures().GetFieldPresence())
+	require.Equal(t, descriptorpb.FeatureSet_NONE, explicit.GetResolvedFeatures().GetUtf8Validation())
+	require.True(t, explicit.HasPresence())
+
+	checked := msg.GetMessageField("checked")
+	require.Equal(t, descriptorpb.FeatureSet_VERIFY, checked.GetResolvedFeatures().GetUtf8Validation())
+	require.Equal(t, descriptorpb.FeatureSet_IMPLICIT, checked.GetResolvedFeatures().GetFieldPresence())
+
+	// fields in a oneof inherit from the oneof
+	kind := msg.GetOneof("kind")
+	require.Equal(t, descriptorpb.FeatureSet_LEGACY_BEST_EFFORT, kind.GetResolvedFeatures().GetJsonFormat())
+	choice := msg.GetMessageField("choice")
+	require.Equal(t, descriptorpb.FeatureSet_LEGACY_BEST_EFFORT, choice.GetResolvedFeatures().GetJsonFormat())
+	require.Equal(t, descriptorpb.FeatureSet_NONE, choice.GetResolvedFeatures().GetUtf8Validation())
+	require.True(t, choice.HasPresence())
+
+	enum := msg.GetEnum("Kind")
+	require.Equal(t, descriptorpb.FeatureSet_CLOSED, enum.GetResolvedFeatures().GetEnumType())
+	require.Equal(t, descriptorpb.FeatureSet_NONE, enum.GetValues()[0].GetResolvedFeatures().GetUtf8Validation())
+	require.Equal(t, descriptorpb.FeatureSet_OPEN, file.GetResolvedFeatures().GetEnumType())
+}
@@ -0,0 +1,180 @@
+package protokit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
+)
+
+// A File is a generated output file
+type File = pluginpb.CodeGeneratorResponse_File
+
+// A FileGenerator generates the output for a single file. Since files are generated independently of each other,
+// `FilePlugin` can generate several of them at the same time, so implementations must be safe for concurrent use.
+type FileGenerator interface {
+	// GenerateFile returns the output files for the given file. The context includes the file (see
+	// `FileDescriptorFromContext`).
+	GenerateFile(ctx context.Context, file *FileDescriptor) ([]*File, error)
+}
+
+// FileGeneratorFunc is an adapter to allow using ordinary functions as a `FileGenerator`
+type FileGeneratorFunc func(ctx context.Context, file *FileDescriptor) ([]*File, error)
+
+// GenerateFile calls f(ctx, file)
+func (f FileGeneratorFunc) GenerateFile(ctx context.Context, file *FileDescriptor) ([]*File, error) {
+	return f(ctx, file)
+}
+
+// FilePlugin is a `Plugin` that runs a `FileGenerator` for each of the files to generate. The request is parsed once
+// and the files are generated concurrently by a bounded number of workers.
+//
+// Errors are collected for all files rather than stopping at the first one and are combined with `errors.Join` (in
+// the order of `req.FileToGenerate`). Errors that don't have a location in the file are prefixed with the file name.
+// Panics are recovered and reported as a `GeneratorError` for the offending file, as are files to generate that
+// aren't in `req.ProtoFile` (wrapping `ErrMissingFile`).
+// The output files are merged in the order of `req.FileToGenerate`, and in the order returned by the generator for
+// each file. When there are errors, the response with the files that were generated successfully is returned along
+// with the error.
+//
+// Once the context is done, no more files are started and an error wrapping `ctx.Err()` is returned.
+type FilePlugin struct {
+	Generator FileGenerator
+	// Concurrency is the maximum number of files generated at the same time. Defaults to `runtime.GOMAXPROCS(0)`.
+	Concurrency int
+}
+
+// NewFilePlugin returns a `FilePlugin` for gen using the default concurrency
+func NewFilePlugin(gen FileGenerator) *FilePlugin {
+	return &FilePlugin{Generator: gen}
+}
+
+// Generate implements `Plugin`
+func (p *FilePlugin) Generate(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+	return p.GenerateContext(context.Background(), req)
+}
+
+// GenerateContext implements `ContextPlugin`. The contexts passed to the generator are derived from ctx. When ctx
+// carries a `Registry` for req (as it does when run by `RunPluginContextWithIO` and the other run functions), it's used
+// rather than parsing the request again.
+func (p *FilePlugin) GenerateContext(
+	ctx context.Context,
+	req *pluginpb.CodeGeneratorRequest,
+) (*pluginpb.CodeGeneratorResponse, error) {
+	files := registryFor(ctx, req).FilesToGenerate()
+	results := make([]fileResult, len(files))
+
+	indexes := make(chan int)
+	wg := new(sync.WaitGroup)
+
+	for range min(p.concurrency(), len(files)) {
+		wg.Go(func() {
+			for i := range indexes {
+				if ctx.Err() == nil {
+					results[i] = p.generateFile(ctx, req.GetFileToGenerate()[i], files[i])
+				}
+			}
+		})
+	}
+
+	for i := range files {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	resp, errs := mergeResults(req, results)
+	if err := ctx.Err(); err != nil {
+		// drop the generators' own context errors in favor of a single one
+		errs = slices.DeleteFunc(errs, func(e error) bool { return errors.Is(e, err) })
+		errs = append(errs, fmt.Errorf("generation aborted: %w", err))
+	}
+
+	return resp, errors.Join(errs...)
+}
+
+// registryFor returns the registry from the context if it was built for req, or parses req otherwise
+func registryFor(ctx context.Context, req *pluginpb.CodeGeneratorRequest) *Registry {
+	reg, ok := RegistryFromContext(ctx)
+	if r, found := RequestFromContext(ctx); ok && found && r == req {
+		return reg
+	}
+
+	return NewRegistry(req)
+}
+
+func (p *FilePlugin) concurrency() int {
+	if p.Concurrency > 0 {
+		return p.Concurrency
+	}
+
+	return runtime.GOMAXPROCS(0)
+}
+
+type fileResult struct {
+	files []*File
+	err   error
+}
+
+func (p *FilePlugin) generateFile(ctx context.Context, name string, file *FileDescriptor) (res fileResult) {
+	if file == nil {
+		return fileResult{err: &GeneratorError{Location: &Location{File: name}, Err: ErrMissingFile}}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			res = fileResult{err: panicError(&Location{File: name}, r)}
+		}
+	}()
+
+	files, err := p.Generator.GenerateFile(ContextWithFileDescriptor(ctx, file), file)
+	return fileResult{files: files, err: fileError(name, err)}
+}
+
+// fileError prefixes err with the name of the file it came from, unless it's a `GeneratorError` that already has a
+// location. The error is wrapped, so it's still reported the same way by the run functions.
+func fileError(name string, err error) error {
+	var genErr *GeneratorError
+	if err == nil || (errors.As(err, &genErr) && genErr.Location != nil && genErr.Location.File != "") {
+		return err
+	}
+
+	return fmt.Errorf("%s: %w", name, err)
+}
+
+// mergeResults returns the response with the files that were generated successfully and the errors for the others.
+// Output names that are generated more than once (excluding insertions) are reported as `ErrDuplicateOutput`.
+func mergeResults(req *pluginpb.CodeGeneratorRequest, results []fileResult) (*pluginpb.CodeGeneratorResponse, []error) {
+	resp := new(pluginpb.CodeGeneratorResponse)
+	names := make(map[string]string) // output name => file that generated it
+
+	var errs []error
+	for i, res := range results {
+		if res.err != nil {
+			errs = append(errs, res.err)
+			continue
+		}
+
+		source := req.GetFileToGenerate()[i]
+		for _, f := range res.files {
+			if f.InsertionPoint == nil {
+				if prev, ok := names[f.GetName()]; ok {
+					err := fmt.Errorf("%w: %s (already generated for %s)", ErrDuplicateOutput, f.GetName(), prev)
+					errs = append(errs, &GeneratorError{Location: &Location{File: source}, Err: err})
+					continue
+				}
+
+				names[f.GetName()] = source
+			}
+
+			resp.File = append(resp.File, f)
+		}
+	}
+
+	return resp, errs
+}
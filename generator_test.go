@@ -0,0 +1,224 @@
+package protokit_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pseudomuto/protokit"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+var allFixtures = []string{
+	"booking.proto",
+	"todo.proto",
+	"todo_import.proto",
+	"extend.proto",
+	"edition2023.proto",
+	"edition2024.proto",
+	"edition2023_implicit.proto",
+}
+
+func TestFilePlugin(t *testing.T) {
+	t.Parallel()
+
+	var running, maxRunning atomic.Int32
+
+	p := &protokit.FilePlugin{Concurrency: 2}
+	p.Generator = protokit.FileGeneratorFunc(func(
+		ctx context.Context,
+		fd *protokit.FileDescriptor,
+	) ([]*protokit.File, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		// give the other workers a chance to start
+		time.Sleep(5 * time.Millisecond)
+
+		file, ok := protokit.FileDescriptorFromContext(ctx)
+		require.True(t, ok)
+		require.Equal(t, fd, file)
+
+		return []*protokit.File{
+			{Name: proto.String(fd.GetName() + ".1"), Content: proto.String(fd.GetPackage())},
+			{Name: proto.String(fd.GetName() + ".2")},
+		}, nil
+	})
+
+	req := fixtureRequest(t, allFixtures...)
+	resp, err := p.Generate(req)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), maxRunning.Load())
+
+	names := make([]string, 0)
+	for _, f := range resp.GetFile() {
+		names = append(names, f.GetName())
+	}
+
+	expected := make([]string, 0)
+	for _, name := range req.GetFileToGenerate() {
+		expected = append(expected, name+".1", name+".2")
+	}
+
+	require.Equal(t, expected, names)
+	require.Equal(t, "com.pseudomuto.protokit.v1", resp.GetFile()[0].GetContent())
+}
+
+func TestFilePluginErrors(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	p := protokit.NewFilePlugin(protokit.FileGeneratorFunc(
+		func(_ context.Context, fd *protokit.FileDescriptor) ([]*protokit.File, error) {
+			calls.Add(1)
+
+			switch fd.GetName() {
+			case "todo.proto":
+				return nil, protokit.NewGeneratorError(fd.GetMessage("List").GetLocation(), "lists aren't supported")
+			case "extend.proto":
+				panic("boom")
+			case "edition2023.proto", "edition2024.proto":
+				return []*protokit.File{{Name: proto.String("editions.txt")}}, nil
+			default:
+				return []*protokit.File{{Name: proto.String(fd.GetName() + ".txt")}}, nil
+			}
+		},
+	))
+
+	resp, err := p.Generate(fixtureRequest(t, allFixtures...))
+	require.Equal(t, int32(len(allFixtures)), calls.Load())
+	require.True(t, errors.Is(err, protokit.ErrPluginPanic))
+	require.True(t, errors.Is(err, protokit.ErrDuplicateOutput))
+
+	// errors are in the order of the files to generate: extend.proto, booking.proto, todo_import.proto, todo.proto...
+	lines := strings.Split(err.Error(), "\n")
+	require.Equal(t, "extend.proto: plugin panicked: boom", lines[0])
+	require.Contains(t, lines, "todo.proto:49:1: lists aren't supported")
+	require.Equal(t, "edition2024.proto: duplicate output file: editions.txt (already generated for edition2023.proto)",
+		lines[len(lines)-1])
+
+	// the files that were generated successfully are still returned
+	names := make([]string, 0)
+	for _, f := range resp.GetFile() {
+		names = append(names, f.GetName())
+	}
+
+	expected := []string{"booking.proto.txt", "todo_import.proto.txt", "editions.txt", "edition2023_implicit.proto.txt"}
+	require.Equal(t, expected, names)
+}
+
+func TestFilePluginRegistryFromContext(t *testing.T) {
+	t.Parallel()
+
+	req := fixtureRequest(t, "booking.proto")
+	reg := protokit.NewRegistry(req)
+
+	var got *protokit.FileDescriptor
+	p := protokit.NewFilePlugin(protokit.FileGeneratorFunc(
+		func(_ context.Context, fd *protokit.FileDescriptor) ([]*protokit.File, error) {
+			got = fd
+			return nil, nil
+		},
+	))
+
+	// the registry is reused when it was built for the same request
+	ctx := protokit.ContextWithRegistry(protokit.ContextWithRequest(context.Background(), req), reg)
+	_, err := p.GenerateContext(ctx, req)
+	require.NoError(t, err)
+	require.Same(t, reg.FilesToGenerate()[0], got)
+
+	// and ignored otherwise
+	_, err = p.GenerateContext(ctx, fixtureRequest(t, "booking.proto"))
+	require.NoError(t, err)
+	require.NotSame(t, reg.FilesToGenerate()[0], got)
+}
+
+func TestFilePluginMissingFile(t *testing.T) {
+	t.Parallel()
+
+	p := protokit.NewFilePlugin(protokit.FileGeneratorFunc(
+		func(context.Context, *protokit.FileDescriptor) ([]*protokit.File, error) {
+			return nil, io.EOF
+		},
+	))
+
+	req := fixtureRequest(t, "booking.proto")
+	req.FileToGenerate = append(req.FileToGenerate, "missing.proto")
+
+	// plain errors are prefixed with the file, and files that aren't in the request aren't silently skipped
+	_, err := p.Generate(req)
+	require.EqualError(t, err, "booking.proto: EOF\nmissing.proto: file not found in request")
+	require.True(t, errors.Is(err, io.EOF))
+	require.True(t, errors.Is(err, protokit.ErrMissingFile))
+
+	var genErr *protokit.GeneratorError
+	require.True(t, errors.As(err, &genErr))
+}
+
+func TestFilePluginCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := &protokit.FilePlugin{Concurrency: 1}
+	p.Generator = protokit.FileGeneratorFunc(func(
+		ctx context.Context,
+		fd *protokit.FileDescriptor,
+	) ([]*protokit.File, error) {
+		if fd.GetName() == "booking.proto" {
+			cancel()
+			return nil, ctx.Err()
+		}
+
+		return []*protokit.File{{Name: proto.String(fd.GetName() + ".txt")}}, nil
+	})
+
+	// extend.proto is generated before booking.proto, the rest are never started
+	resp, err := p.GenerateContext(ctx, fixtureRequest(t, allFixtures...))
+	require.EqualError(t, err, "generation aborted: context canceled")
+	require.True(t, errors.Is(err, context.Canceled))
+	require.Len(t, resp.GetFile(), 1)
+	require.Equal(t, "extend.proto.txt", resp.GetFile()[0].GetName())
+}
+
+func TestFilePluginInsertions(t *testing.T) {
+	t.Parallel()
+
+	p := protokit.NewFilePlugin(protokit.FileGeneratorFunc(
+		func(context.Context, *protokit.FileDescriptor) ([]*protokit.File, error) {
+			return []*protokit.File{{Name: proto.String("all.txt"), InsertionPoint: proto.String("files")}}, nil
+		},
+	))
+
+	resp, err := p.Generate(fixtureRequest(t, "booking.proto", "todo.proto"))
+	require.NoError(t, err)
+	require.Len(t, resp.GetFile(), 2)
+}
+
+func TestRunFilePlugin(t *testing.T) {
+	t.Parallel()
+
+	p := protokit.NewFilePlugin(protokit.FileGeneratorFunc(
+		func(_ context.Context, fd *protokit.FileDescriptor) ([]*protokit.File, error) {
+			return nil, protokit.NewGeneratorError(fd.GetLocation(), "nope")
+		},
+	))
+
+	resp := runPlugin(t, p, fixtureRequest(t, "booking.proto", "todo.proto"))
+	require.Equal(t, "booking.proto:1:1: nope\ntodo.proto:2:1: nope", resp.GetError())
+	require.Empty(t, resp.GetFile())
+}
@@ -3,20 +3,23 @@ module github.com/pseudomuto/protokit
 go 1.25.1
 
 require (
-	github.com/stretchr/testify v1.2.1
+	github.com/bufbuild/protocompile v0.14.1
+	github.com/pmezard/go-difflib v1.0.0
+	// protocompile requires testify v1.9.0 (and its yaml.v3 dependency), so it can't stay at v1.2.1
+	github.com/stretchr/testify v1.9.0
 	google.golang.org/genproto/googleapis/api v0.0.0-20241015192408-796eee8c2d53
 	google.golang.org/protobuf v1.36.10
 )
 
 require (
-	github.com/davecgh/go-spew v1.1.0 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	golang.org/x/mod v0.26.0 // indirect
 	golang.org/x/sync v0.16.0 // indirect
 	golang.org/x/sys v0.34.0 // indirect
 	golang.org/x/telemetry v0.0.0-20250710130107-8d8967aff50b // indirect
 	golang.org/x/tools v0.35.1-0.20250728180453-01a3475a31bc // indirect
 	golang.org/x/tools/gopls v0.20.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 tool golang.org/x/tools/gopls/internal/analysis/modernize/cmd/modernize
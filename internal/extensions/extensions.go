@@ -0,0 +1,105 @@
+// Package extensions resolves the extensions (i.e. custom options) declared in a set of proto files. It's shared by
+// protokit, which decodes options found in requests, and utils, which parses descriptor sets encoded as JSON or text.
+package extensions
+
+import (
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// A Resolver resolves extension fields and message types. Extensions linked into the running binary (i.e. registered in
+// `protoregistry.GlobalTypes`) take precedence so that their values decode into the generated Go types. Everything
+// else is resolved from the extensions declared in the files the resolver was built from. Messages are only resolved
+// from the global registry (which is needed to parse JSON).
+type Resolver struct {
+	types *protoregistry.Types
+}
+
+// NewResolver builds a resolver for every extension declared in the given files, including the ones nested in
+// messages. The files should include all of their dependencies (as a `CodeGeneratorRequest` does).
+//
+// If the files can't be linked as a whole (e.g. a dependency is missing), they're linked one at a time with
+// unresolvable references replaced by placeholders, so the extensions declared in the other files are still resolved.
+// Only the files that can't be linked at all are skipped.
+func NewResolver(protos []*descriptorpb.FileDescriptorProto) *Resolver {
+	r := &Resolver{types: new(protoregistry.Types)}
+
+	files, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: protos})
+	if err != nil {
+		files = linkFiles(protos)
+	}
+
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		r.registerExtensions(fd.Extensions())
+		r.registerMessageExtensions(fd.Messages())
+		return true
+	})
+
+	return r
+}
+
+// linkFiles links each file on its own, allowing references that can't be resolved. Files are linked in order, so
+// references to files that come earlier are resolved.
+func linkFiles(protos []*descriptorpb.FileDescriptorProto) *protoregistry.Files {
+	files := new(protoregistry.Files)
+
+	for _, pf := range protos {
+		fd, err := (protodesc.FileOptions{AllowUnresolvable: true}).New(pf, files)
+		if err != nil {
+			continue
+		}
+
+		// conflicts can only happen with malformed input, in which case the first definition wins
+		_ = files.RegisterFile(fd)
+	}
+
+	return files
+}
+
+func (r *Resolver) registerMessageExtensions(msgs protoreflect.MessageDescriptors) {
+	for i := range msgs.Len() {
+		r.registerExtensions(msgs.Get(i).Extensions())
+		r.registerMessageExtensions(msgs.Get(i).Messages())
+	}
+}
+
+func (r *Resolver) registerExtensions(exts protoreflect.ExtensionDescriptors) {
+	for i := range exts.Len() {
+		// conflicts can only happen with malformed input, in which case the first definition wins
+		_ = r.types.RegisterExtension(dynamicpb.NewExtensionType(exts.Get(i)))
+	}
+}
+
+// FindExtensionByName implements `protoregistry.ExtensionTypeResolver`
+func (r *Resolver) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	if xt, err := protoregistry.GlobalTypes.FindExtensionByName(field); err == nil {
+		return xt, nil
+	}
+
+	return r.types.FindExtensionByName(field)
+}
+
+// FindExtensionByNumber implements `protoregistry.ExtensionTypeResolver`
+func (r *Resolver) FindExtensionByNumber(
+	message protoreflect.FullName,
+	field protoreflect.FieldNumber,
+) (protoreflect.ExtensionType, error) {
+	if xt, err := protoregistry.GlobalTypes.FindExtensionByNumber(message, field); err == nil {
+		return xt, nil
+	}
+
+	return r.types.FindExtensionByNumber(message, field)
+}
+
+// FindMessageByName implements `protoregistry.MessageTypeResolver` using the global registry
+func (r *Resolver) FindMessageByName(message protoreflect.FullName) (protoreflect.MessageType, error) {
+	return protoregistry.GlobalTypes.FindMessageByName(message)
+}
+
+// FindMessageByURL implements `protoregistry.MessageTypeResolver` using the global registry
+func (r *Resolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	return protoregistry.GlobalTypes.FindMessageByURL(url)
+}
@@ -0,0 +1,54 @@
+package extensions_test
+
+import (
+	"testing"
+
+	"github.com/pseudomuto/protokit/internal/extensions"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	r := extensions.NewResolver([]*descriptorpb.FileDescriptorProto{
+		{
+			Name:       proto.String("opts.proto"),
+			Package:    proto.String("p"),
+			Dependency: []string{"google/protobuf/descriptor.proto"},
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name:      proto.String("Opts"),
+				Extension: []*descriptorpb.FieldDescriptorProto{extension("label", 5000)},
+			}},
+		},
+	})
+
+	// the file doesn't need to be linkable as a whole (descriptor.proto isn't included)
+	xt, err := r.FindExtensionByName("p.Opts.label")
+	require.NoError(t, err)
+	require.Equal(t, "google.protobuf.MessageOptions", string(xt.TypeDescriptor().ContainingMessage().FullName()))
+
+	xt, err = r.FindExtensionByNumber("google.protobuf.MessageOptions", 5000)
+	require.NoError(t, err)
+	require.Equal(t, "p.Opts.label", string(xt.TypeDescriptor().FullName()))
+
+	// extensions linked into the binary use the generated types
+	xt, err = r.FindExtensionByName("google.api.http")
+	require.NoError(t, err)
+	require.Equal(t, annotations.E_Http, xt)
+
+	_, err = r.FindExtensionByName("p.missing")
+	require.Error(t, err)
+}
+
+func extension(name string, number int32) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+		Extendee: proto.String(".google.protobuf.MessageOptions"),
+	}
+}
@@ -0,0 +1,49 @@
+package extensions
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// A TypeResolver resolves extensions and messages, which is what the JSON and text formats need
+type TypeResolver interface {
+	protoregistry.ExtensionTypeResolver
+	protoregistry.MessageTypeResolver
+}
+
+// An UnmarshalFunc parses data into m using the given resolver, e.g. with `protojson` or `prototext`. Since the first
+// pass of `Unmarshal` doesn't know the custom options yet, it must discard unknown fields.
+type UnmarshalFunc func(data []byte, m proto.Message, r TypeResolver) error
+
+// Unmarshal parses a message that embeds proto files (e.g. a `FileDescriptorSet` or a `CodeGeneratorRequest`) and is
+// encoded in a format that needs the types up front, like JSON. Custom options can only be parsed once the files
+// declaring them are known, so data is parsed twice: once with the global types to get the files, and again with a
+// `Resolver` for the extensions declared in them. The result is then converted to the binary format and back into m,
+// so custom options that aren't linked into the binary are unknown fields, like they are when reading the binary
+// format.
+func Unmarshal[M proto.Message](
+	data []byte,
+	m M,
+	unmarshal UnmarshalFunc,
+	files func(M) []*descriptorpb.FileDescriptorProto,
+) error {
+	first := proto.CloneOf(m)
+	proto.Reset(first)
+
+	if err := unmarshal(data, first, protoregistry.GlobalTypes); err != nil {
+		return err
+	}
+
+	resolved := m.ProtoReflect().New().Interface()
+	if err := unmarshal(data, resolved, NewResolver(files(first))); err != nil {
+		return err
+	}
+
+	bin, err := proto.Marshal(resolved)
+	if err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(bin, m)
+}
@@ -0,0 +1,95 @@
+package protokit
+
+import (
+	"iter"
+)
+
+// The file iterators below visit messages depth-first in declaration order (see `AllMessages`). The elements declared
+// in each scope are yielded when the scope is reached, i.e. before the ones declared in its nested messages. So a
+// file's own enums and extensions come first, followed by the ones declared in its messages.
+
+// AllMessages returns an iterator over every message in the file, including nested messages (and map entries).
+// Messages are yielded depth-first, each one before its nested messages.
+func (f *FileDescriptor) AllMessages() iter.Seq[*Descriptor] {
+	return func(yield func(*Descriptor) bool) {
+		allMessages(f.GetMessages(), yield)
+	}
+}
+
+// AllEnums returns an iterator over every enum in the file, including the ones nested in messages
+func (f *FileDescriptor) AllEnums() iter.Seq[*EnumDescriptor] {
+	return func(yield func(*EnumDescriptor) bool) {
+		for _, e := range f.GetEnums() {
+			if !yield(e) {
+				return
+			}
+		}
+
+		for m := range f.AllMessages() {
+			for _, e := range m.GetEnums() {
+				if !yield(e) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// AllFields returns an iterator over the fields of every message in the file (extensions aren't included, see
+// `AllExtensions`)
+func (f *FileDescriptor) AllFields() iter.Seq[*FieldDescriptor] {
+	return func(yield func(*FieldDescriptor) bool) {
+		for m := range f.AllMessages() {
+			for _, field := range m.GetMessageFields() {
+				if !yield(field) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// AllExtensions returns an iterator over every extension declared in the file, including the ones declared in messages
+func (f *FileDescriptor) AllExtensions() iter.Seq[*ExtensionDescriptor] {
+	return func(yield func(*ExtensionDescriptor) bool) {
+		for _, ext := range f.GetExtensions() {
+			if !yield(ext) {
+				return
+			}
+		}
+
+		for m := range f.AllMessages() {
+			for _, ext := range m.GetExtensions() {
+				if !yield(ext) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// AllMethods returns an iterator over the methods of every service in the registry. Files are iterated in the same
+// order as `Files`.
+func (r *Registry) AllMethods() iter.Seq[*MethodDescriptor] {
+	return func(yield func(*MethodDescriptor) bool) {
+		for _, f := range r.Files() {
+			for _, svc := range f.GetServices() {
+				for _, m := range svc.GetMethods() {
+					if !yield(m) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+func allMessages(msgs []*Descriptor, yield func(*Descriptor) bool) bool {
+	for _, m := range msgs {
+		if !yield(m) || !allMessages(m.GetMessages(), yield) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,117 @@
+package protokit_test
+
+import (
+	"iter"
+	"testing"
+
+	"github.com/pseudomuto/protokit"
+	"github.com/pseudomuto/protokit/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func longNames[T interface{ GetLongName() string }](seq iter.Seq[T]) []string {
+	names := make([]string, 0)
+	for v := range seq {
+		names = append(names, v.GetLongName())
+	}
+
+	return names
+}
+
+func TestFileIterators(t *testing.T) {
+	t.Parallel()
+
+	reg := protokit.NewRegistry(fixtureRequest(t, "booking.proto", "todo.proto"))
+	todo := reg.FindFile("todo.proto")
+
+	require.Equal(t, []string{
+		"List",
+		"CreateListRequest",
+		"CreateListResponse",
+		"CreateListResponse.Status",
+		"Item",
+		"AddItemRequest",
+		"AddItemResponse",
+	}, longNames(todo.AllMessages()))
+
+	require.Equal(t, []string{"ListType", "Item.Status"}, longNames(todo.AllEnums()))
+
+	fields := longNames(todo.AllFields())
+	require.Len(t, fields, 18)
+	require.Equal(t, []string{"CreateListResponse.list", "CreateListResponse.status", "CreateListResponse.Status.code"},
+		fields[6:9])
+
+	booking := reg.FindFile("booking.proto")
+	require.Equal(t, []string{"BookingStatus.country", "BookingStatus.optional_field_1"},
+		longNames(booking.AllExtensions()))
+}
+
+func TestFileIteratorsOrder(t *testing.T) {
+	t.Parallel()
+
+	fds, err := utils.CompileProtoSources(map[string]string{
+		"order.proto": `syntax = "proto2";
+			enum A { A0 = 0; }
+			message M {
+				enum B { B0 = 0; }
+				message N {
+					enum C { C0 = 0; }
+					extend M { optional int32 n = 101; }
+				}
+				extend M { optional int32 m = 100; }
+				extensions 100 to 200;
+			}
+			enum D { D0 = 0; }
+			message O { enum E { E0 = 0; } }
+			extend M { optional int32 o = 102; }`,
+	}, "order.proto")
+	require.NoError(t, err)
+
+	f := protokit.NewRegistry(utils.CreateGenRequest(fds, "order.proto")).FindFile("order.proto")
+
+	// each scope's own elements come before the ones in its nested messages
+	require.Equal(t, []string{"M", "M.N", "O"}, longNames(f.AllMessages()))
+	require.Equal(t, []string{"A", "D", "M.B", "M.N.C", "O.E"}, longNames(f.AllEnums()))
+	require.Equal(t, []string{"M.o", "M.m", "M.n"}, longNames(f.AllExtensions()))
+}
+
+func TestIteratorsStopEarly(t *testing.T) {
+	t.Parallel()
+
+	todo := protokit.NewRegistry(fixtureRequest(t, "todo.proto")).FindFile("todo.proto")
+
+	names := make([]string, 0)
+	for m := range todo.AllMessages() {
+		names = append(names, m.GetLongName())
+		if m.GetLongName() == "CreateListResponse.Status" {
+			break
+		}
+	}
+
+	require.Equal(t, []string{"List", "CreateListRequest", "CreateListResponse", "CreateListResponse.Status"}, names)
+
+	next, stop := iter.Pull(todo.AllEnums())
+	defer stop()
+
+	e, ok := next()
+	require.True(t, ok)
+	require.Equal(t, "ListType", e.GetLongName())
+}
+
+func TestRegistryAllMethods(t *testing.T) {
+	t.Parallel()
+
+	reg := protokit.NewRegistry(fixtureRequest(t, "booking.proto", "todo.proto"))
+
+	methods := make([]string, 0)
+	for m := range reg.AllMethods() {
+		methods = append(methods, m.GetFullName())
+	}
+
+	require.Equal(t, []string{
+		"com.pseudomuto.protokit.v1.BookingService.BookVehicle",
+		"com.pseudomuto.protokit.v1.Todo.CreateList",
+		"com.pseudomuto.protokit.v1.Todo.AddItem",
+	}, methods[:3])
+	require.Len(t, methods, 7)
+}
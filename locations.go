@@ -0,0 +1,165 @@
+package protokit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// A Location describes where an object is defined within a proto file. Lines and columns start at 1 (unlike the `span`
+// in `SourceCodeInfo_Location`, which is zero-based), so they can be used as is when reporting positions to users. A
+// zero `StartLine` means the position isn't known (e.g. the request doesn't include source info).
+type Location struct {
+	File        string
+	StartLine   int
+	StartColumn int
+	EndLine     int
+	EndColumn   int
+}
+
+// IsValid returns whether or not the position is known
+func (l *Location) IsValid() bool { return l.StartLine > 0 }
+
+// String returns the start of the location formatted as `file:line:column` (e.g. `booking.proto:42:3`). When the
+// position isn't known, only the file name is returned.
+func (l *Location) String() string {
+	if !l.IsValid() {
+		return l.File
+	}
+
+	return fmt.Sprintf("%s:%d:%d", l.File, l.StartLine, l.StartColumn)
+}
+
+func newLocation(file string, loc *descriptorpb.SourceCodeInfo_Location) *Location {
+	span := loc.GetSpan()
+
+	switch len(span) {
+	case 3:
+		return &Location{
+			File:        file,
+			StartLine:   int(span[0]) + 1,
+			StartColumn: int(span[1]) + 1,
+			EndLine:     int(span[0]) + 1,
+			EndColumn:   int(span[2]) + 1,
+		}
+	case 4:
+		return &Location{
+			File:        file,
+			StartLine:   int(span[0]) + 1,
+			StartColumn: int(span[1]) + 1,
+			EndLine:     int(span[2]) + 1,
+			EndColumn:   int(span[3]) + 1,
+		}
+	default:
+		return &Location{File: file}
+	}
+}
+
+// Locations is a map of source location paths to values.
+type Locations map[string]*Location
+
+// ParseLocations parses the positions of all elements within a proto file. The paths are encoded the same way as they
+// are for `ParseComments`. E.g. `4.2.3.0`.
+//
+// When an element has more than one location (e.g. a field within an `extend` block), the first one is used. The
+// location of the file itself (the empty path) is always included, even when the file has no source info.
+func ParseLocations(fd *descriptorpb.FileDescriptorProto) Locations {
+	locations := make(Locations)
+
+	for _, loc := range fd.GetSourceCodeInfo().GetLocation() {
+		key := sourcePathKey(loc.GetPath())
+		if _, ok := locations[key]; !ok {
+			locations[key] = newLocation(fd.GetName(), loc)
+		}
+	}
+
+	if _, ok := locations[""]; !ok {
+		locations[""] = &Location{File: fd.GetName()}
+	}
+
+	return locations
+}
+
+// Get returns the location for the given path. If there isn't one, an invalid location (with no position) that only
+// has the file name is returned.
+func (l Locations) Get(path string) *Location {
+	if val, ok := l[path]; ok {
+		return val
+	}
+
+	var file string
+	if val, ok := l[""]; ok {
+		file = val.File
+	}
+
+	return &Location{File: file}
+}
+
+// optionLocations returns the positions of the options set on the object at path, keyed by option name. Standard
+// options use their field name (e.g. `deprecated`) and custom options use the extension's full name (the same key as
+// in `OptionExtensions`). The options must already be resolved (see `resolveOptions`).
+func optionLocations(locations Locations, path string, msg protoreflect.Message) map[string]*Location {
+	prefix := strconv.Itoa(int(optionsPath(msg.Interface())))
+	if path != "" {
+		prefix = path + "." + prefix
+	}
+
+	var m map[string]*Location
+	msg.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		loc, ok := locations[fmt.Sprintf("%s.%d", prefix, fd.Number())]
+		if !ok {
+			return true
+		}
+
+		name := string(fd.Name())
+		if fd.IsExtension() {
+			name = string(fd.FullName())
+		}
+
+		if m == nil {
+			m = make(map[string]*Location)
+		}
+		m[name] = loc
+		return true
+	})
+
+	return m
+}
+
+// optionsPath returns the tag number of the `options` field in the descriptor that the options belong to
+func optionsPath(options proto.Message) int32 {
+	switch options.(type) {
+	case *descriptorpb.FileOptions:
+		return fileOptionsPath
+	case *descriptorpb.MessageOptions:
+		return messageOptionsPath
+	case *descriptorpb.FieldOptions:
+		return fieldOptionsPath
+	case *descriptorpb.OneofOptions:
+		return oneofOptionsPath
+	case *descriptorpb.EnumOptions:
+		return enumOptionsPath
+	case *descriptorpb.EnumValueOptions:
+		return enumValueOptionsPath
+	case *descriptorpb.ServiceOptions:
+		return serviceOptionsPath
+	case *descriptorpb.MethodOptions:
+		return methodOptionsPath
+	default:
+		return -1
+	}
+}
+
+// sourcePathKey joins the path of a `SourceCodeInfo_Location` with "." characters
+func sourcePathKey(path []int32) string {
+	key := make([]string, len(path))
+	for idx, p := range path {
+		key[idx] = strconv.Itoa(int(p))
+	}
+
+	return strings.Join(key, ".")
+}
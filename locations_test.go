@@ -0,0 +1,134 @@
+package protokit_test
+
+import (
+	"testing"
+
+	"github.com/pseudomuto/protokit"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestLocations(t *testing.T) {
+	t.Parallel()
+
+	file := protokit.ParseCodeGenRequest(fixtureRequest(t, "booking.proto"))[0]
+	require.Equal(t, &protokit.Location{File: "booking.proto", StartLine: 1, StartColumn: 1, EndLine: 89, EndColumn: 2},
+		file.GetLocation())
+	require.Equal(t, "booking.proto:12:1", file.GetOptionLocation("com.pseudomuto.protokit.v1.extend_file").String())
+
+	svc := file.GetService("BookingService")
+	require.Equal(t, "booking.proto:17:1", svc.GetLocation().String())
+	require.Equal(t, "booking.proto:18:3", svc.GetOptionLocation("com.pseudomuto.protokit.v1.extend_service").String())
+
+	method := svc.GetNamedMethod("BookVehicle")
+	require.Equal(t, &protokit.Location{File: "booking.proto", StartLine: 21, StartColumn: 3, EndLine: 23, EndColumn: 4},
+		method.GetLocation())
+	require.Equal(t, "booking.proto:22:5", method.GetOptionLocation("com.pseudomuto.protokit.v1.extend_method").String())
+
+	enum := file.GetEnum("BookingType")
+	require.Equal(t, "booking.proto:54:1", enum.GetLocation().String())
+	require.Equal(t, "booking.proto:58:3", enum.GetNamedValue("FUTURE").GetLocation().String())
+	require.Equal(t, "booking.proto:58:20",
+		enum.GetNamedValue("FUTURE").GetOptionLocation("com.pseudomuto.protokit.v1.extend_enum_value").String())
+
+	msg := file.GetMessage("Booking")
+	require.Equal(t, "booking.proto:66:1", msg.GetLocation().String())
+	require.Equal(t, "booking.proto:79:3", msg.GetOneof("things").GetLocation().String())
+	require.Equal(t, "booking.proto:87:5", msg.GetExtensions()[0].GetLocation().String())
+
+	// single line spans have the same start and end line
+	field := msg.GetMessageField("vehicle_id")
+	require.Equal(t, &protokit.Location{File: "booking.proto", StartLine: 69, StartColumn: 3, EndLine: 69, EndColumn: 37},
+		field.GetLocation())
+
+	ext := file.GetExtensions()[0]
+	require.Equal(t, "booking.proto:48:3", ext.GetLocation().String())
+	require.Equal(t, "booking.proto:48:53", ext.GetOptionLocation("com.pseudomuto.protokit.v1.extend_field").String())
+
+	// options that aren't set have no position
+	require.False(t, field.GetOptionLocation("deprecated").IsValid())
+}
+
+func TestLocationsReservedAndStandardOptions(t *testing.T) {
+	t.Parallel()
+
+	loc := func(span []int32, path ...int32) *descriptorpb.SourceCodeInfo_Location {
+		return &descriptorpb.SourceCodeInfo_Location{Path: path, Span: span}
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"reserved.proto"},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{{
+			Name:   proto.String("reserved.proto"),
+			Syntax: proto.String("proto3"),
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name:          proto.String("Thing"),
+				Options:       &descriptorpb.MessageOptions{Deprecated: proto.Bool(true)},
+				ReservedRange: []*descriptorpb.DescriptorProto_ReservedRange{{Start: proto.Int32(2), End: proto.Int32(3)}},
+				ReservedName:  []string{"old"},
+			}},
+			EnumType: []*descriptorpb.EnumDescriptorProto{{
+				Name:  proto.String("Kind"),
+				Value: []*descriptorpb.EnumValueDescriptorProto{{Name: proto.String("KIND_A"), Number: proto.Int32(0)}},
+				ReservedRange: []*descriptorpb.EnumDescriptorProto_EnumReservedRange{
+					{Start: proto.Int32(1), End: proto.Int32(1)},
+				},
+				ReservedName: []string{"KIND_B"},
+			}},
+			SourceCodeInfo: &descriptorpb.SourceCodeInfo{Location: []*descriptorpb.SourceCodeInfo_Location{
+				loc([]int32{0, 0, 12, 1}),
+				loc([]int32{2, 0, 6, 1}, 4, 0),
+				loc([]int32{3, 2, 21}, 4, 0, 7, 3),
+				loc([]int32{4, 11, 12}, 4, 0, 9, 0),
+				loc([]int32{5, 11, 16}, 4, 0, 10, 0),
+				loc([]int32{8, 0, 11, 1}, 5, 0),
+				loc([]int32{10, 11, 12}, 5, 0, 4, 0),
+				loc([]int32{10, 11, 12}, 5, 0, 4, 0), // duplicates are ignored
+			}},
+		}},
+	}
+
+	file := protokit.ParseCodeGenRequest(req)[0]
+
+	msg := file.GetMessage("Thing")
+	require.Equal(t, "reserved.proto:3:1", msg.GetLocation().String())
+	require.Equal(t, "reserved.proto:4:3", msg.GetOptionLocation("deprecated").String())
+	require.Equal(t, &protokit.Location{File: "reserved.proto", StartLine: 5, StartColumn: 12, EndLine: 5, EndColumn: 13},
+		msg.GetReservedRangeLocation(0))
+	require.Equal(t, "reserved.proto:6:12", msg.GetReservedNameLocation(0).String())
+	require.False(t, msg.GetReservedRangeLocation(1).IsValid())
+
+	enum := file.GetEnum("Kind")
+	require.Equal(t, "reserved.proto:9:1", enum.GetLocation().String())
+	require.Equal(t, "reserved.proto:11:12", enum.GetReservedRangeLocation(0).String())
+	require.False(t, enum.GetReservedNameLocation(0).IsValid())
+
+	// elements without source info have invalid locations
+	value := enum.GetNamedValue("KIND_A")
+	require.False(t, value.GetLocation().IsValid())
+}
+
+func TestLocationsWithoutPosition(t *testing.T) {
+	t.Parallel()
+
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:        proto.String("plain.proto"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Thing")}},
+	}
+
+	// unknown paths still have the file name, whether they're looked up directly or through the parsed descriptors
+	locations := protokit.ParseLocations(fd)
+	require.Equal(t, &protokit.Location{File: "plain.proto"}, locations.Get("4.0"))
+	require.Equal(t, "plain.proto", locations.Get("4.7.2.0").String())
+
+	file := protokit.ParseCodeGenRequest(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"plain.proto"},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{fd},
+	})[0]
+
+	require.Equal(t, locations.Get(""), file.GetLocation())
+	require.Equal(t, locations.Get("4.0"), file.GetMessage("Thing").GetLocation())
+	require.Equal(t, "plain.proto", file.GetMessage("Thing").GetOptionLocation("deprecated").String())
+}
@@ -0,0 +1,134 @@
+package protokit
+
+import (
+	"context"
+
+	"github.com/pseudomuto/protokit/internal/extensions"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// getOptions returns all extension values set on the given options message keyed by the extension's full name.
+//
+// Values are decoded as follows:
+//
+//   - singular scalars are returned as pointers (e.g. `*bool`, `*string`, `*int32`), bytes as `[]byte`
+//   - singular enums are returned as `*protoreflect.EnumNumber`
+//   - messages are returned as `proto.Message`. This is the generated Go type when it's linked into the binary, or a
+//     `*dynamicpb.Message` otherwise
+//   - repeated fields are returned as slices of the non-pointer element types (e.g. `[]string`, `[]proto.Message`)
+//
+// The options must already be resolved (see `resolveOptions`). Unknown fields are ignored.
+func getOptions(msg protoreflect.Message) (m map[string]any) {
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.IsExtension() {
+			if m == nil {
+				m = make(map[string]any)
+			}
+			m[string(fd.FullName())] = extensionValue(fd, v)
+		}
+		return true
+	})
+
+	return m
+}
+
+// resolveOptions returns the options with custom options turned into extension fields. Extensions that aren't linked
+// into the binary are kept as unknown fields when the request is unmarshalled, so the options are round tripped through
+// the resolver. If there are no unknown fields, or they can't be resolved, the options are returned as is.
+func resolveOptions(ctx context.Context, options proto.Message) protoreflect.Message {
+	msg := options.ProtoReflect()
+	if len(msg.GetUnknown()) == 0 {
+		return msg
+	}
+
+	resolver, ok := extensionResolverFromContext(ctx)
+	if !ok {
+		resolver = extensions.NewResolver(nil)
+	}
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(options)
+	if err != nil {
+		return msg
+	}
+
+	resolved := msg.New()
+	if err := (proto.UnmarshalOptions{Resolver: resolver}).Unmarshal(data, resolved.Interface()); err != nil {
+		return msg
+	}
+
+	return resolved
+}
+
+func extensionValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	if fd.IsList() {
+		return listValue(fd, v.List())
+	}
+
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return v.Message().Interface()
+	case protoreflect.EnumKind:
+		return ptr(v.Enum())
+	case protoreflect.BytesKind:
+		return v.Bytes()
+	case protoreflect.BoolKind:
+		return ptr(v.Bool())
+	case protoreflect.StringKind:
+		return ptr(v.String())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return ptr(int32(v.Int()))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return ptr(v.Int())
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return ptr(uint32(v.Uint()))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return ptr(v.Uint())
+	case protoreflect.FloatKind:
+		return ptr(float32(v.Float()))
+	case protoreflect.DoubleKind:
+		return ptr(v.Float())
+	default:
+		return v.Interface()
+	}
+}
+
+func listValue(fd protoreflect.FieldDescriptor, l protoreflect.List) any {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return listOf(l, func(v protoreflect.Value) proto.Message { return v.Message().Interface() })
+	case protoreflect.EnumKind:
+		return listOf(l, protoreflect.Value.Enum)
+	case protoreflect.BytesKind:
+		return listOf(l, protoreflect.Value.Bytes)
+	case protoreflect.BoolKind:
+		return listOf(l, protoreflect.Value.Bool)
+	case protoreflect.StringKind:
+		return listOf(l, protoreflect.Value.String)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return listOf(l, func(v protoreflect.Value) int32 { return int32(v.Int()) })
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return listOf(l, protoreflect.Value.Int)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return listOf(l, func(v protoreflect.Value) uint32 { return uint32(v.Uint()) })
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return listOf(l, protoreflect.Value.Uint)
+	case protoreflect.FloatKind:
+		return listOf(l, func(v protoreflect.Value) float32 { return float32(v.Float()) })
+	case protoreflect.DoubleKind:
+		return listOf(l, protoreflect.Value.Float)
+	default:
+		return listOf(l, protoreflect.Value.Interface)
+	}
+}
+
+func listOf[T any](l protoreflect.List, conv func(protoreflect.Value) T) []T {
+	values := make([]T, l.Len())
+	for i := range l.Len() {
+		values[i] = conv(l.Get(i))
+	}
+
+	return values
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,245 @@
+package protokit_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pseudomuto/protokit"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
+)
+
+// customOptionsRequest builds a request for a file that uses custom options declared in another file. The option values
+// are stored as unknown fields, which is how they show up in a plugin that doesn't link in the extension's Go types.
+func customOptionsRequest() *pluginpb.CodeGeneratorRequest {
+	options := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("options.proto"),
+		Package:    proto.String("acme.options"),
+		Syntax:     proto.String("proto2"),
+		Dependency: []string{"google/protobuf/descriptor.proto"},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Level"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("LOW"), Number: proto.Int32(0)},
+				{Name: proto.String("HIGH"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Meta"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{
+					Name:   proto.String("owner"),
+					Number: proto.Int32(1),
+					Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				},
+				{
+					Name:   proto.String("tags"),
+					Number: proto.Int32(2),
+					Label:  descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+					Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				},
+			},
+			Extension: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("weight"),
+				Number:   proto.Int32(50005),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_DOUBLE.Enum(),
+				Extendee: proto.String(".google.protobuf.FieldOptions"),
+			}},
+		}},
+		Extension: []*descriptorpb.FieldDescriptorProto{
+			{
+				Name:     proto.String("label"),
+				Number:   proto.Int32(50001),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				Extendee: proto.String(".google.protobuf.MessageOptions"),
+			},
+			{
+				Name:     proto.String("level"),
+				Number:   proto.Int32(50002),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum(),
+				TypeName: proto.String(".acme.options.Level"),
+				Extendee: proto.String(".google.protobuf.MessageOptions"),
+			},
+			{
+				Name:     proto.String("meta"),
+				Number:   proto.Int32(50003),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".acme.options.Meta"),
+				Extendee: proto.String(".google.protobuf.MessageOptions"),
+			},
+			{
+				Name:     proto.String("codes"),
+				Number:   proto.Int32(50004),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+				Extendee: proto.String(".google.protobuf.MessageOptions"),
+			},
+		},
+	}
+
+	var meta []byte
+	meta = protowire.AppendTag(meta, 1, protowire.BytesType)
+	meta = protowire.AppendString(meta, "team-a")
+	meta = protowire.AppendTag(meta, 2, protowire.BytesType)
+	meta = protowire.AppendString(meta, "x")
+	meta = protowire.AppendTag(meta, 2, protowire.BytesType)
+	meta = protowire.AppendString(meta, "y")
+
+	var msgOpts []byte
+	msgOpts = protowire.AppendTag(msgOpts, 50001, protowire.BytesType)
+	msgOpts = protowire.AppendString(msgOpts, "thing")
+	msgOpts = protowire.AppendTag(msgOpts, 50002, protowire.VarintType)
+	msgOpts = protowire.AppendVarint(msgOpts, 1)
+	msgOpts = protowire.AppendTag(msgOpts, 50003, protowire.BytesType)
+	msgOpts = protowire.AppendBytes(msgOpts, meta)
+	msgOpts = protowire.AppendTag(msgOpts, 50004, protowire.VarintType)
+	msgOpts = protowire.AppendVarint(msgOpts, 4)
+	msgOpts = protowire.AppendTag(msgOpts, 50004, protowire.VarintType)
+	msgOpts = protowire.AppendVarint(msgOpts, 2)
+	msgOpts = protowire.AppendTag(msgOpts, 59999, protowire.VarintType) // not declared anywhere
+	msgOpts = protowire.AppendVarint(msgOpts, 1)
+
+	var fieldOpts []byte
+	fieldOpts = protowire.AppendTag(fieldOpts, 50005, protowire.Fixed64Type)
+	fieldOpts = protowire.AppendFixed64(fieldOpts, math.Float64bits(1.5))
+
+	var rule []byte
+	rule = protowire.AppendTag(rule, 2, protowire.BytesType) // get
+	rule = protowire.AppendString(rule, "/v1/things")
+
+	var methodOpts []byte
+	methodOpts = protowire.AppendTag(methodOpts, 72295728, protowire.BytesType) // google.api.http
+	methodOpts = protowire.AppendBytes(methodOpts, rule)
+
+	thing := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("thing.proto"),
+		Package:    proto.String("acme.things"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"options.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name:    proto.String("Thing"),
+			Options: unknownOptions(new(descriptorpb.MessageOptions), msgOpts),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("size"),
+				JsonName: proto.String("size"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+				Options:  unknownOptions(new(descriptorpb.FieldOptions), fieldOpts),
+			}},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Things"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("List"),
+				InputType:  proto.String(".acme.things.Thing"),
+				OutputType: proto.String(".acme.things.Thing"),
+				Options:    unknownOptions(new(descriptorpb.MethodOptions), methodOpts),
+			}},
+		}},
+	}
+
+	return &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"thing.proto"},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(descriptorpb.File_google_protobuf_descriptor_proto),
+			options,
+			thing,
+		},
+	}
+}
+
+func unknownOptions[T proto.Message](opts T, raw []byte) T {
+	opts.ProtoReflect().SetUnknown(raw)
+	return opts
+}
+
+func TestCustomOptions(t *testing.T) {
+	t.Parallel()
+
+	files := protokit.ParseCodeGenRequest(customOptionsRequest())
+	require.Len(t, files, 1)
+
+	msg := files[0].GetMessage("Thing")
+	require.Len(t, msg.OptionExtensions, 4)
+
+	label, ok := msg.OptionExtensions["acme.options.label"].(*string)
+	require.True(t, ok)
+	require.Equal(t, "thing", *label)
+
+	level, ok := msg.OptionExtensions["acme.options.level"].(*protoreflect.EnumNumber)
+	require.True(t, ok)
+	require.Equal(t, protoreflect.EnumNumber(1), *level)
+
+	meta, ok := msg.OptionExtensions["acme.options.meta"].(*dynamicpb.Message)
+	require.True(t, ok)
+	require.Equal(t, "team-a", meta.Get(meta.Descriptor().Fields().ByName("owner")).String())
+	require.Equal(t, 2, meta.Get(meta.Descriptor().Fields().ByName("tags")).List().Len())
+
+	codes, ok := msg.OptionExtensions["acme.options.codes"].([]int32)
+	require.True(t, ok)
+	require.Equal(t, []int32{4, 2}, codes)
+
+	field := msg.GetMessageField("size")
+	weight, ok := field.OptionExtensions["acme.options.Meta.weight"].(*float64)
+	require.True(t, ok)
+	require.InDelta(t, 1.5, *weight, 0)
+}
+
+func TestCustomOptionsUnlinkableRequest(t *testing.T) {
+	t.Parallel()
+
+	// a file with a missing dependency and an unresolvable type means the request can't be linked as a whole
+	req := customOptionsRequest()
+	req.ProtoFile = append(req.ProtoFile, &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("broken.proto"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"missing.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Broken"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("missing"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".Missing"),
+			}},
+		}},
+	})
+
+	files := protokit.ParseCodeGenRequest(req)
+	msg := files[0].GetMessage("Thing")
+	require.Len(t, msg.OptionExtensions, 4)
+
+	label, ok := msg.OptionExtensions["acme.options.label"].(*string)
+	require.True(t, ok)
+	require.Equal(t, "thing", *label)
+
+	meta, ok := msg.OptionExtensions["acme.options.meta"].(*dynamicpb.Message)
+	require.True(t, ok)
+	require.Equal(t, "team-a", meta.Get(meta.Descriptor().Fields().ByName("owner")).String())
+}
+
+func TestCustomOptionsLinkedTypes(t *testing.T) {
+	t.Parallel()
+
+	files := protokit.ParseCodeGenRequest(customOptionsRequest())
+	method := files[0].GetService("Things").GetNamedMethod("List")
+
+	// google.api.http isn't declared in the request, but its Go type is linked in so it resolves to the generated type
+	rule, ok := method.OptionExtensions["google.api.http"].(*annotations.HttpRule)
+	require.True(t, ok)
+	require.Equal(t, "/v1/things", rule.GetGet())
+}
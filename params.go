@@ -0,0 +1,206 @@
+package protokit
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidParam indicates that the plugin parameter is malformed or that a value couldn't be applied to a flag
+	ErrInvalidParam = errors.New("invalid parameter")
+
+	// ErrUnknownParam indicates that a parameter doesn't match any of the defined flags
+	ErrUnknownParam = errors.New("unknown parameter")
+)
+
+// A Param is a single entry of the plugin parameter. It's either a `key=value` pair or a bare flag (e.g. `verbose`).
+type Param struct {
+	Key   string
+	Value string
+	Bare  bool
+}
+
+// Params are the entries of the plugin parameter in the order they were specified. Keys can be repeated.
+type Params []Param
+
+// ParseParams parses the plugin parameter (`req.GetParameter()`). Entries are separated by commas and are either
+// `key=value` pairs or bare flags. Values can be quoted to include commas or surrounding spaces. Double-quoted values
+// support the same escapes as Go strings, single-quoted values are taken literally. Quotes only start a quoted value at
+// the beginning of the value, elsewhere they're part of it (e.g. `title=O'Brien`).
+//
+// For example, `--foo_out=paths=source_relative,include="a,b",verbose:.` has the parameter
+// `paths=source_relative,include="a,b",verbose`, which results in 3 entries.
+func ParseParams(s string) (Params, error) {
+	entries, err := splitParams(s)
+	if err != nil {
+		return nil, err
+	}
+
+	params := make(Params, 0, len(entries))
+	for _, entry := range entries {
+		key, value, hasValue := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+
+		if key == "" || strings.ContainsAny(key, `"'`) {
+			return nil, fmt.Errorf("%w: %q has an invalid key", ErrInvalidParam, entry)
+		}
+
+		if !hasValue {
+			params = append(params, Param{Key: key, Bare: true})
+			continue
+		}
+
+		value, err := unquoteParam(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidParam, key, err)
+		}
+
+		params = append(params, Param{Key: key, Value: value})
+	}
+
+	return params, nil
+}
+
+// Get returns the last value for key. Bare flags and missing keys return an empty string.
+func (p Params) Get(key string) string {
+	value, _ := p.Lookup(key)
+	return value
+}
+
+// Lookup returns the last value for key and whether or not the key was found
+func (p Params) Lookup(key string) (string, bool) {
+	for i := len(p) - 1; i >= 0; i-- {
+		if p[i].Key == key {
+			return p[i].Value, true
+		}
+	}
+
+	return "", false
+}
+
+// Has returns whether or not the key was specified (either as a `key=value` pair or a bare flag)
+func (p Params) Has(key string) bool {
+	_, ok := p.Lookup(key)
+	return ok
+}
+
+// Values returns all values for key in the order they were specified
+func (p Params) Values(key string) []string {
+	values := make([]string, 0)
+	for _, param := range p {
+		if param.Key == key {
+			values = append(values, param.Value)
+		}
+	}
+
+	return values
+}
+
+// BindFlags sets the flags in fs from the parameters. Each entry is applied in order using `fs.Set`, so repeated keys
+// replace earlier values for the standard flag types and accumulate for `flag.Value` implementations that support it
+// (e.g. flags defined with `fs.Func`). Bare flags are only allowed for boolean flags and set them to true.
+//
+// Binding the flags to the fields of an options struct (e.g. `fs.StringVar(&opts.Mode, "mode", "", "...")`) populates
+// the struct. All problems are returned, combined with `errors.Join`. Keys that don't match any flag wrap
+// `ErrUnknownParam`, and values that can't be applied wrap `ErrInvalidParam`.
+func (p Params) BindFlags(fs *flag.FlagSet) error {
+	var errs []error
+
+	for _, param := range p {
+		f := fs.Lookup(param.Key)
+		if f == nil {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrUnknownParam, param.Key))
+			continue
+		}
+
+		value := param.Value
+		if param.Bare {
+			if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !bf.IsBoolFlag() {
+				errs = append(errs, fmt.Errorf("%w: %s requires a value", ErrInvalidParam, param.Key))
+				continue
+			}
+
+			value = "true"
+		}
+
+		if err := fs.Set(param.Key, value); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s=%s: %w", ErrInvalidParam, param.Key, value, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// splitParams splits the parameter on commas that aren't quoted. Empty entries are dropped. A quote only opens a
+// quoted value when it's the first non-space character after the entry's first `=`.
+func splitParams(s string) ([]string, error) {
+	var (
+		entries    []string
+		quote      rune
+		escaped    bool
+		start      int
+		seenEquals bool // the entry's first `=` has been seen
+		valueStart bool // only spaces have been seen since then
+	)
+
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == ',':
+			entries = append(entries, s[start:i])
+			start = i + 1
+			seenEquals, valueStart = false, false
+		case r == '=' && !seenEquals:
+			seenEquals, valueStart = true, true
+		case valueStart && (r == '"' || r == '\''):
+			quote = r
+			valueStart = false
+		case r != ' ':
+			valueStart = false
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("%w: unterminated quote in %q", ErrInvalidParam, s[start:])
+	}
+
+	entries = append(entries, s[start:])
+
+	nonEmpty := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if strings.TrimSpace(e) != "" {
+			nonEmpty = append(nonEmpty, e)
+		}
+	}
+
+	return nonEmpty, nil
+}
+
+func unquoteParam(value string) (string, error) {
+	if value == "" {
+		return value, nil
+	}
+
+	switch value[0] {
+	case '"':
+		return strconv.Unquote(value)
+	case '\'':
+		if len(value) < 2 || value[len(value)-1] != '\'' {
+			return "", fmt.Errorf("malformed quoted value %s", value)
+		}
+
+		return value[1 : len(value)-1], nil
+	default:
+		return value, nil
+	}
+}
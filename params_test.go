@@ -0,0 +1,151 @@
+package protokit_test
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pseudomuto/protokit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseParams(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		param  string
+		params protokit.Params
+	}{
+		{"", protokit.Params{}},
+		{"paths=source_relative", protokit.Params{{Key: "paths", Value: "source_relative"}}},
+		{"a=1,b=2,a=3", protokit.Params{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}, {Key: "a", Value: "3"}}},
+		{"verbose,mode=x", protokit.Params{{Key: "verbose", Bare: true}, {Key: "mode", Value: "x"}}},
+		{" a = 1 ,, b= ,", protokit.Params{{Key: "a", Value: "1"}, {Key: "b"}}},
+		{"expr=a=b", protokit.Params{{Key: "expr", Value: "a=b"}}},
+		{`include="a,b",x=' c, d '`, protokit.Params{{Key: "include", Value: "a,b"}, {Key: "x", Value: " c, d "}}},
+		{`q="say \"hi\",\tok"`, protokit.Params{{Key: "q", Value: "say \"hi\",\tok"}}},
+		{`q='it\'`, protokit.Params{{Key: "q", Value: `it\`}}},
+		// quotes inside a value are taken literally
+		{`title=O'Brien,k=ab"c`, protokit.Params{{Key: "title", Value: "O'Brien"}, {Key: "k", Value: `ab"c`}}},
+		{`expr=a="b"`, protokit.Params{{Key: "expr", Value: `a="b"`}}},
+		{`say=it's,a= 'b,c'`, protokit.Params{{Key: "say", Value: "it's"}, {Key: "a", Value: "b,c"}}},
+	}
+
+	for _, test := range tests {
+		params, err := protokit.ParseParams(test.param)
+		require.NoError(t, err, test.param)
+		require.Equal(t, test.params, params, test.param)
+	}
+}
+
+func TestParseParamsErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		param string
+		err   string
+	}{
+		{"=value", `invalid parameter: "=value" has an invalid key`},
+		{`"a"=b`, `invalid parameter: "\"a\"=b" has an invalid key`},
+		{`a="b`, `invalid parameter: unterminated quote in "a=\"b"`},
+		{`a=x,b='c`, `invalid parameter: unterminated quote in "b='c"`},
+		{`a="b"c`, "invalid parameter: a: invalid syntax"},
+	}
+
+	for _, test := range tests {
+		_, err := protokit.ParseParams(test.param)
+		require.EqualError(t, err, test.err, test.param)
+		require.True(t, errors.Is(err, protokit.ErrInvalidParam))
+	}
+}
+
+func TestParamsLookup(t *testing.T) {
+	t.Parallel()
+
+	params, err := protokit.ParseParams("a=1,verbose,a=2")
+	require.NoError(t, err)
+
+	require.Equal(t, "2", params.Get("a"))
+	require.Equal(t, []string{"1", "2"}, params.Values("a"))
+	require.True(t, params.Has("verbose"))
+	require.Empty(t, params.Get("verbose"))
+	require.False(t, params.Has("b"))
+	require.Empty(t, params.Values("b"))
+}
+
+func TestParamsBindFlags(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mode     string
+		verbose  bool
+		count    int
+		includes []string
+	)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.StringVar(&mode, "mode", "default", "")
+	fs.BoolVar(&verbose, "verbose", false, "")
+	fs.IntVar(&count, "count", 0, "")
+	fs.Func("include", "", func(s string) error {
+		includes = append(includes, s)
+		return nil
+	})
+
+	params, err := protokit.ParseParams(`mode=a,verbose,count=3,include=x,include="y,z",mode=b`)
+	require.NoError(t, err)
+	require.NoError(t, params.BindFlags(fs))
+
+	require.Equal(t, "b", mode)
+	require.True(t, verbose)
+	require.Equal(t, 3, count)
+	require.Equal(t, []string{"x", "y,z"}, includes)
+}
+
+func TestParamsBindFlagsErrors(t *testing.T) {
+	t.Parallel()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("mode", "", "")
+	fs.Int("count", 0, "")
+
+	params, err := protokit.ParseParams("whodis=1,mode,count=many,other")
+	require.NoError(t, err)
+
+	err = params.BindFlags(fs)
+	require.True(t, errors.Is(err, protokit.ErrUnknownParam))
+	require.True(t, errors.Is(err, protokit.ErrInvalidParam))
+
+	lines := strings.Split(err.Error(), "\n")
+	require.Len(t, lines, 4)
+	require.Equal(t, "unknown parameter: whodis", lines[0])
+	require.Equal(t, "invalid parameter: mode requires a value", lines[1])
+	require.Contains(t, lines[2], "invalid parameter: count=many: ")
+	require.Equal(t, "unknown parameter: other", lines[3])
+}
+
+func ExampleParams_BindFlags() {
+	var opts struct {
+		Paths   string
+		Verbose bool
+	}
+
+	fs := flag.NewFlagSet("protoc-gen-example", flag.ContinueOnError)
+	fs.StringVar(&opts.Paths, "paths", "import", "how output paths are determined")
+	fs.BoolVar(&opts.Verbose, "verbose", false, "enable verbose output")
+
+	// e.g. protoc --example_out=paths=source_relative,verbose:. ...
+	params, err := protokit.ParseParams("paths=source_relative,verbose")
+	if err != nil {
+		panic(err)
+	}
+
+	if err := params.BindFlags(fs); err != nil {
+		panic(err)
+	}
+
+	fmt.Println(opts.Paths, opts.Verbose)
+	// Output: source_relative true
+}
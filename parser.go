@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/pseudomuto/protokit/internal/extensions"
 	"google.golang.org/protobuf/types/descriptorpb"
 	pluginpb "google.golang.org/protobuf/types/pluginpb"
 )
@@ -21,38 +22,91 @@ const (
 	editionCommentPath   = 14
 
 	// tag numbers in DescriptorProto
-	messageFieldCommentPath     = 2 // field
-	messageMessageCommentPath   = 3 // nested_type
-	messageEnumCommentPath      = 4 // enum_type
-	messageExtensionCommentPath = 6 // extension
+	messageFieldCommentPath     = 2  // field
+	messageMessageCommentPath   = 3  // nested_type
+	messageEnumCommentPath      = 4  // enum_type
+	messageExtensionCommentPath = 6  // extension
+	messageOneofCommentPath     = 8  // oneof_decl
+	messageReservedRangePath    = 9  // reserved_range
+	messageReservedNamePath     = 10 // reserved_name
 
 	// tag numbers in EnumDescriptorProto
-	enumValueCommentPath = 2 // value
+	enumValueCommentPath  = 2 // value
+	enumReservedRangePath = 4 // reserved_range
+	enumReservedNamePath  = 5 // reserved_name
 
 	// tag numbers in ServiceDescriptorProto
 	serviceMethodCommentPath = 2
+
+	// tag numbers of the options field in each descriptor proto
+	fileOptionsPath      = 8
+	messageOptionsPath   = 7
+	fieldOptionsPath     = 8
+	oneofOptionsPath     = 2
+	enumOptionsPath      = 3
+	enumValueOptionsPath = 3
+	serviceOptionsPath   = 3
+	methodOptionsPath    = 4
 )
 
 // ParseCodeGenRequest parses the given request into `FileDescriptor` objects. Only the `req.FilesToGenerate` will be
 // returned.
 //
+// Custom options are decoded into `OptionExtensions` using the extensions declared in `req.ProtoFile`, so the Go types
+// for those extensions don't need to be linked into the plugin.
+//
 // For example, given the following invocation, only booking.proto will be returned even if it imports other protos:
 //
 //	protoc --plugin=protoc-gen-test=./test -I. protos/booking.proto
+//
+// The request is assumed to be well-formed (as it is when it comes from protoc). Entries for files that aren't in
+// `req.ProtoFile` will be `nil`, and unresolvable references are left unset. Use `ParseCodeGenRequestE` to have the
+// request validated.
 func ParseCodeGenRequest(req *pluginpb.CodeGeneratorRequest) []*FileDescriptor {
-	allFiles := make(map[string]*FileDescriptor)
-	genFiles := make([]*FileDescriptor, len(req.GetFileToGenerate()))
+	return parseRequest(req).FilesToGenerate()
+}
+
+// ParseCodeGenRequestE is like `ParseCodeGenRequest`, but validates the request first. If any problems are found, no
+// files are returned and the error contains a `*ParseError` for each of them (combined using `errors.Join`).
+//
+// The request is checked for files to generate and dependencies that aren't in `req.ProtoFile`, duplicate file and
+// symbol names, type references that can't be resolved and `SourceCodeInfo` locations that don't match the file.
+func ParseCodeGenRequestE(req *pluginpb.CodeGeneratorRequest) ([]*FileDescriptor, error) {
+	reg := parseRequest(req)
+	if err := validateRequest(req, reg.filesByName); err != nil {
+		return nil, err
+	}
+
+	return reg.FilesToGenerate(), nil
+}
+
+func parseRequest(req *pluginpb.CodeGeneratorRequest) *Registry {
+	reg := newRegistry()
+	ctx := contextWithExtensionResolver(context.Background(), extensions.NewResolver(req.GetProtoFile()))
 
+	files := make([]*FileDescriptor, 0, len(req.GetProtoFile()))
 	for _, pf := range req.GetProtoFile() {
-		allFiles[pf.GetName()] = parseFile(context.Background(), pf)
+		// the first file wins when a name is included more than once
+		if reg.FindFile(pf.GetName()) == nil {
+			files = append(files, parseFile(ctx, pf))
+			reg.addFile(files[len(files)-1])
+		}
 	}
 
-	for i, f := range req.GetFileToGenerate() {
-		genFiles[i] = allFiles[f]
-		parseImports(genFiles[i], allFiles)
+	reg.linkTypes()
+	linkDependencies(files, reg.filesByName)
+	reg.files = SortFiles(files)
+
+	for _, name := range req.GetFileToGenerate() {
+		f := reg.FindFile(name)
+		if f != nil {
+			parseImports(f)
+		}
+
+		reg.filesToGenerate = append(reg.filesToGenerate, f)
 	}
 
-	return genFiles
+	return reg
 }
 
 func parseFile(ctx context.Context, fd *descriptorpb.FileDescriptorProto) *FileDescriptor {
@@ -60,14 +114,17 @@ func parseFile(ctx context.Context, fd *descriptorpb.FileDescriptorProto) *FileD
 
 	file := &FileDescriptor{
 		comments:            comments,
+		locations:           ParseLocations(fd),
 		FileDescriptorProto: fd,
 		PackageComments:     comments.Get(strconv.Itoa(packageCommentPath)),
 		SyntaxComments:      comments.Get(strconv.Itoa(syntaxCommentPath)),
 		EditionComments:     comments.Get(strconv.Itoa(editionCommentPath)),
 	}
 
+	file.Location = file.location("")
+
 	if fd.Options != nil {
-		file.setOptions(fd.Options)
+		file.setOptions(ctx, fd.Options)
 	}
 
 	fileCtx := ContextWithFileDescriptor(ctx, file)
@@ -75,6 +132,7 @@ func parseFile(ctx context.Context, fd *descriptorpb.FileDescriptorProto) *FileD
 	file.Extensions = parseExtensions(fileCtx, fd.GetExtension())
 	file.Messages = parseMessages(fileCtx, fd.GetMessageType())
 	file.Services = parseServices(fileCtx, fd.GetService())
+	resolveFeatures(file)
 
 	return file
 }
@@ -100,7 +158,7 @@ func parseEnums(ctx context.Context, protos []*descriptorpb.EnumDescriptorProto)
 			Parent:              parent,
 		}
 		if ed.Options != nil {
-			enums[i].setOptions(ed.Options)
+			enums[i].setOptions(ctx, ed.Options)
 		}
 
 		subCtx := ContextWithEnumDescriptor(ctx, enums[i])
@@ -117,15 +175,16 @@ func parseEnumValues(ctx context.Context, protos []*descriptorpb.EnumValueDescri
 
 	for i, vd := range protos {
 		longName := fmt.Sprintf("%s.%s", enum.GetLongName(), vd.GetName())
+		commentPath := fmt.Sprintf("%s.%d.%d", enum.path, enumValueCommentPath, i)
 
 		values[i] = &EnumValueDescriptor{
-			common:                   newCommon(file, "", longName),
+			common:                   newCommon(file, commentPath, longName),
 			EnumValueDescriptorProto: vd,
 			Enum:                     enum,
-			Comments:                 file.comments.Get(fmt.Sprintf("%s.%d.%d", enum.path, enumValueCommentPath, i)),
+			Comments:                 file.comments.Get(commentPath),
 		}
 		if vd.Options != nil {
-			values[i].setOptions(vd.Options)
+			values[i].setOptions(ctx, vd.Options)
 		}
 	}
 
@@ -157,22 +216,20 @@ func parseExtensions(ctx context.Context, protos []*descriptorpb.FieldDescriptor
 			Parent:               parent,
 		}
 		if ext.Options != nil {
-			exts[i].setOptions(ext.Options)
+			exts[i].setOptions(ctx, ext.Options)
 		}
 	}
 
 	return exts
 }
 
-func parseImports(fd *FileDescriptor, allFiles map[string]*FileDescriptor) {
+func parseImports(fd *FileDescriptor) {
 	fd.Imports = make([]*ImportedDescriptor, 0)
 
-	for _, index := range fd.GetPublicDependency() {
-		file := allFiles[fd.GetDependency()[index]]
-
+	for _, file := range fd.GetPublicDependencies() {
 		for _, d := range file.GetMessages() {
 			// skip map entry objects
-			if !d.GetOptions().GetMapEntry() {
+			if !d.IsMapEntry() {
 				fd.Imports = append(fd.Imports, &ImportedDescriptor{d.common})
 			}
 		}
@@ -187,6 +244,73 @@ func parseImports(fd *FileDescriptor, allFiles map[string]*FileDescriptor) {
 	}
 }
 
+// linkDependencies links each file to the files it imports and vice versa. Dependencies that aren't in the request are
+// skipped.
+func linkDependencies(files []*FileDescriptor, allFiles map[string]*FileDescriptor) {
+	for _, fd := range files {
+		fd.Dependencies = dependencyFiles(fd.GetDependency(), allFiles)
+		fd.PublicDependencies = dependencyFiles(dependencyNames(fd, fd.GetPublicDependency()), allFiles)
+		fd.WeakDependencies = dependencyFiles(dependencyNames(fd, fd.GetWeakDependency()), allFiles)
+
+		for _, dep := range fd.Dependencies {
+			dep.ImportedBy = append(dep.ImportedBy, fd)
+		}
+	}
+}
+
+// dependencyFiles returns the parsed files with the given names, skipping any that aren't in the request
+func dependencyFiles(names []string, allFiles map[string]*FileDescriptor) []*FileDescriptor {
+	files := make([]*FileDescriptor, 0, len(names))
+	for _, name := range names {
+		if dep, ok := allFiles[name]; ok {
+			files = append(files, dep)
+		}
+	}
+
+	return files
+}
+
+// dependencyNames returns the names of the dependencies at the given indexes (e.g. `public_dependency`)
+func dependencyNames(fd *FileDescriptor, indexes []int32) []string {
+	names := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		if index >= 0 && int(index) < len(fd.GetDependency()) {
+			names = append(names, fd.GetDependency()[index])
+		}
+	}
+
+	return names
+}
+
+// SortFiles returns the given files along with all of their transitive dependencies in topological order. That is,
+// every file comes after the files it imports. Apart from that, the order of the given files is preserved.
+//
+// Sorting the result of `ParseCodeGenRequest` returns every file in the request that the files to generate depend on.
+func SortFiles(files []*FileDescriptor) []*FileDescriptor {
+	sorted := make([]*FileDescriptor, 0, len(files))
+	visited := make(map[*FileDescriptor]bool)
+
+	var visit func(*FileDescriptor)
+	visit = func(f *FileDescriptor) {
+		if f == nil || visited[f] {
+			return
+		}
+
+		visited[f] = true
+		for _, dep := range f.GetDependencies() {
+			visit(dep)
+		}
+
+		sorted = append(sorted, f)
+	}
+
+	for _, f := range files {
+		visit(f)
+	}
+
+	return sorted
+}
+
 func parseMessages(ctx context.Context, protos []*descriptorpb.DescriptorProto) []*Descriptor {
 	msgs := make([]*Descriptor, len(protos))
 	file, _ := FileDescriptorFromContext(ctx)
@@ -208,7 +332,7 @@ func parseMessages(ctx context.Context, protos []*descriptorpb.DescriptorProto)
 			Parent:          parent,
 		}
 		if md.Options != nil {
-			msgs[i].setOptions(md.Options)
+			msgs[i].setOptions(ctx, md.Options)
 		}
 
 		msgCtx := ContextWithDescriptor(ctx, msgs[i])
@@ -216,6 +340,7 @@ func parseMessages(ctx context.Context, protos []*descriptorpb.DescriptorProto)
 		msgs[i].Extensions = parseExtensions(msgCtx, md.GetExtension())
 		msgs[i].Fields = parseMessageFields(msgCtx, md.GetField())
 		msgs[i].Messages = parseMessages(msgCtx, md.GetNestedType())
+		msgs[i].Oneofs = parseOneofs(msgCtx, md.GetOneofDecl())
 	}
 
 	return msgs
@@ -228,21 +353,54 @@ func parseMessageFields(ctx context.Context, protos []*descriptorpb.FieldDescrip
 
 	for i, fd := range protos {
 		longName := fmt.Sprintf("%s.%s", message.GetLongName(), fd.GetName())
+		commentPath := fmt.Sprintf("%s.%d.%d", message.path, messageFieldCommentPath, i)
 
 		fields[i] = &FieldDescriptor{
-			common:               newCommon(file, "", longName),
+			common:               newCommon(file, commentPath, longName),
 			FieldDescriptorProto: fd,
-			Comments:             file.comments.Get(fmt.Sprintf("%s.%d.%d", message.path, messageFieldCommentPath, i)),
+			Comments:             file.comments.Get(commentPath),
 			Message:              message,
 		}
 		if fd.Options != nil {
-			fields[i].setOptions(fd.Options)
+			fields[i].setOptions(ctx, fd.Options)
 		}
 	}
 
 	return fields
 }
 
+func parseOneofs(ctx context.Context, protos []*descriptorpb.OneofDescriptorProto) []*OneofDescriptor {
+	oneofs := make([]*OneofDescriptor, len(protos))
+	file, _ := FileDescriptorFromContext(ctx)
+	message, _ := DescriptorFromContext(ctx)
+
+	for i, od := range protos {
+		longName := fmt.Sprintf("%s.%s", message.GetLongName(), od.GetName())
+		commentPath := fmt.Sprintf("%s.%d.%d", message.path, messageOneofCommentPath, i)
+
+		oneofs[i] = &OneofDescriptor{
+			common:               newCommon(file, commentPath, longName),
+			OneofDescriptorProto: od,
+			Comments:             file.comments.Get(commentPath),
+			Message:              message,
+		}
+		if od.Options != nil {
+			oneofs[i].setOptions(ctx, od.Options)
+		}
+	}
+
+	for _, f := range message.GetMessageFields() {
+		if f.OneofIndex == nil || int(f.GetOneofIndex()) >= len(oneofs) {
+			continue
+		}
+
+		f.Oneof = oneofs[f.GetOneofIndex()]
+		f.Oneof.Fields = append(f.Oneof.Fields, f)
+	}
+
+	return oneofs
+}
+
 func parseServices(ctx context.Context, protos []*descriptorpb.ServiceDescriptorProto) []*ServiceDescriptor {
 	svcs := make([]*ServiceDescriptor, len(protos))
 	file, _ := FileDescriptorFromContext(ctx)
@@ -257,7 +415,7 @@ func parseServices(ctx context.Context, protos []*descriptorpb.ServiceDescriptor
 			Comments:               file.comments.Get(commentPath),
 		}
 		if sd.Options != nil {
-			svcs[i].setOptions(sd.Options)
+			svcs[i].setOptions(ctx, sd.Options)
 		}
 
 		svcCtx := ContextWithServiceDescriptor(ctx, svcs[i])
@@ -275,15 +433,16 @@ func parseServiceMethods(ctx context.Context, protos []*descriptorpb.MethodDescr
 
 	for i, md := range protos {
 		longName := fmt.Sprintf("%s.%s", svc.GetLongName(), md.GetName())
+		commentPath := fmt.Sprintf("%s.%d.%d", svc.path, serviceMethodCommentPath, i)
 
 		methods[i] = &MethodDescriptor{
-			common:                newCommon(file, "", longName),
+			common:                newCommon(file, commentPath, longName),
 			MethodDescriptorProto: md,
 			Service:               svc,
-			Comments:              file.comments.Get(fmt.Sprintf("%s.%d.%d", svc.path, serviceMethodCommentPath, i)),
+			Comments:              file.comments.Get(commentPath),
 		}
 		if md.Options != nil {
-			methods[i].setOptions(md.Options)
+			methods[i].setOptions(ctx, md.Options)
 		}
 	}
 
@@ -6,6 +6,11 @@ import (
 	"github.com/pseudomuto/protokit"
 	"github.com/pseudomuto/protokit/utils"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
 )
 
 func setupParserTest(t *testing.T) (*protokit.FileDescriptor, *protokit.FileDescriptor) {
@@ -49,6 +54,55 @@ func TestFileImports(t *testing.T) {
 	require.Equal(t, "com.pseudomuto.protokit.v1.ListItemDetails", imp.GetFullName())
 }
 
+func fileNames(files []*protokit.FileDescriptor) []string {
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.GetName()
+	}
+
+	return names
+}
+
+func TestFileDependencies(t *testing.T) {
+	t.Parallel()
+
+	proto2, proto3 := setupParserTest(t)
+
+	require.Equal(t, []string{
+		"google/protobuf/any.proto",
+		"google/protobuf/timestamp.proto",
+		"extend.proto",
+		"todo_import.proto",
+	}, fileNames(proto3.GetDependencies()))
+	require.Equal(t, []string{"todo_import.proto"}, fileNames(proto3.GetPublicDependencies()))
+	require.Empty(t, proto3.GetWeakDependencies())
+	require.Equal(t, []string{"todo.proto"}, fileNames(proto3.GetPublicDependencies()[0].GetImportedBy()))
+	require.Empty(t, proto3.GetImportedBy())
+
+	extend := proto2.GetDependencies()[0]
+	require.Equal(t, "extend.proto", extend.GetName())
+	require.Contains(t, extend.GetImportedBy(), proto2)
+	require.Contains(t, extend.GetImportedBy(), proto3)
+
+	require.Equal(t, []string{
+		"google/protobuf/any.proto",
+		"google/protobuf/timestamp.proto",
+		"google/protobuf/descriptor.proto",
+		"extend.proto",
+		"todo_import.proto",
+	}, fileNames(proto3.GetTransitiveDependencies()))
+
+	require.Equal(t, []string{
+		"google/protobuf/descriptor.proto",
+		"extend.proto",
+		"booking.proto",
+		"google/protobuf/any.proto",
+		"google/protobuf/timestamp.proto",
+		"todo_import.proto",
+		"todo.proto",
+	}, fileNames(protokit.SortFiles([]*protokit.FileDescriptor{proto2, proto3})))
+}
+
 func TestFileEnums(t *testing.T) {
 	t.Parallel()
 
@@ -118,6 +172,54 @@ func TestServices(t *testing.T) {
 	require.Nil(t, svc.GetNamedMethod("wat"))
 }
 
+func TestMethodTypes(t *testing.T) {
+	t.Parallel()
+
+	proto2, proto3 := setupParserTest(t)
+
+	m := proto3.GetService("Todo").GetNamedMethod("CreateList")
+	require.Equal(t, proto3.GetMessage("CreateListRequest"), m.GetInput())
+	require.Equal(t, proto3.GetMessage("CreateListResponse"), m.GetOutput())
+
+	m = proto2.GetService("BookingService").GetNamedMethod("BookVehicle")
+	require.Equal(t, proto2.GetMessage("Booking"), m.GetInput())
+	require.Equal(t, proto2.GetMessage("BookingStatus"), m.GetOutput())
+}
+
+func TestMethodTypesFromDependencies(t *testing.T) {
+	t.Parallel()
+
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"pinger.proto"},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(emptypb.File_google_protobuf_empty_proto),
+			{
+				Name:       proto.String("pinger.proto"),
+				Package:    proto.String("acme.pinger"),
+				Syntax:     proto.String("proto3"),
+				Dependency: []string{"google/protobuf/empty.proto"},
+				MessageType: []*descriptorpb.DescriptorProto{
+					{Name: proto.String("Pong")},
+				},
+				Service: []*descriptorpb.ServiceDescriptorProto{{
+					Name: proto.String("Pinger"),
+					Method: []*descriptorpb.MethodDescriptorProto{{
+						Name:       proto.String("Ping"),
+						InputType:  proto.String(".google.protobuf.Empty"),
+						OutputType: proto.String(".acme.pinger.Pong"),
+					}},
+				}},
+			},
+		},
+	}
+
+	file := protokit.ParseCodeGenRequest(req)[0]
+	m := file.GetService("Pinger").GetNamedMethod("Ping")
+	require.Equal(t, "google.protobuf.Empty", m.GetInput().GetFullName())
+	require.Equal(t, "google/protobuf/empty.proto", m.GetInput().GetFile().GetName())
+	require.Equal(t, file.GetMessage("Pong"), m.GetOutput())
+}
+
 func TestFileMessages(t *testing.T) {
 	t.Parallel()
 
@@ -162,6 +264,170 @@ func TestFileMessages(t *testing.T) {
 	require.Equal(t, "the numeric reference number", m.GetMessageField("reference_num").GetComments().String())
 }
 
+func TestMessageOneofs(t *testing.T) {
+	t.Parallel()
+
+	proto2, proto3 := setupParserTest(t)
+
+	m := proto2.GetMessage("Booking")
+	require.Len(t, m.GetOneofs(), 1)
+	require.Nil(t, m.GetOneof("whodis"))
+
+	o := m.GetOneof("things")
+	require.Equal(t, o, m.GetOneof("Booking.things"))
+	require.Equal(t, "Booking.things", o.GetLongName())
+	require.Equal(t, "com.pseudomuto.protokit.v1.Booking.things", o.GetFullName())
+	require.Equal(t, m, o.GetMessage())
+	require.NotNil(t, o.GetFile())
+	require.False(t, o.IsSynthetic())
+	require.Len(t, o.GetFields(), 2)
+	require.Equal(t, m.GetMessageField("reference_num"), o.GetFields()[0])
+	require.Equal(t, m.GetMessageField("reference_tag"), o.GetFields()[1])
+	require.Equal(t, o, m.GetMessageField("reference_tag").GetOneof())
+	require.Nil(t, m.GetMessageField("vehicle_id").GetOneof())
+
+	require.Empty(t, proto3.GetMessage("List").GetOneofs())
+}
+
+func TestSyntheticOneofs(t *testing.T) {
+	t.Parallel()
+
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"optional.proto"},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{{
+			Name:    proto.String("optional.proto"),
+			Package: proto.String("acme.optional"),
+			Syntax:  proto.String("proto3"),
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("Thing"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						Name:           proto.String("size"),
+						Number:         proto.Int32(1),
+						Label:          descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:           descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+						OneofIndex:     proto.Int32(1),
+						Proto3Optional: proto.Bool(true),
+					},
+					{
+						Name:       proto.String("id"),
+						Number:     proto.Int32(2),
+						Label:      descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:       descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(),
+						OneofIndex: proto.Int32(0),
+					},
+				},
+				OneofDecl: []*descriptorpb.OneofDescriptorProto{
+					{Name: proto.String("key")},
+					{Name: proto.String("_size")},
+				},
+			}},
+			SourceCodeInfo: &descriptorpb.SourceCodeInfo{
+				Location: []*descriptorpb.SourceCodeInfo_Location{
+					{Path: []int32{4, 0, 8, 0}, LeadingComments: proto.String(" The key of the thing.\n")},
+				},
+			},
+		}},
+	}
+
+	m := protokit.ParseCodeGenRequest(req)[0].GetMessage("Thing")
+	require.Len(t, m.GetOneofs(), 2)
+
+	key := m.GetOneof("key")
+	require.False(t, key.IsSynthetic())
+	require.Equal(t, "The key of the thing.", key.GetComments().String())
+	require.Equal(t, []*protokit.FieldDescriptor{m.GetMessageField("id")}, key.GetFields())
+
+	size := m.GetOneof("_size")
+	require.True(t, size.IsSynthetic())
+	require.Empty(t, size.GetComments().String())
+	require.Equal(t, size, m.GetMessageField("size").GetOneof())
+}
+
+func TestMapFields(t *testing.T) {
+	t.Parallel()
+
+	mapEntry := func(name string, key, value *descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+		key.Name, key.Number = proto.String("key"), proto.Int32(1)
+		value.Name, value.Number = proto.String("value"), proto.Int32(2)
+
+		return &descriptorpb.DescriptorProto{
+			Name:    proto.String(name),
+			Field:   []*descriptorpb.FieldDescriptorProto{key, value},
+			Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+		}
+	}
+
+	repeated := func(name string, number int32, typeName string) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+			TypeName: proto.String(typeName),
+		}
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"inventory.proto"},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{{
+			Name:    proto.String("inventory.proto"),
+			Package: proto.String("acme.inventory"),
+			Syntax:  proto.String("proto3"),
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("Inventory"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					repeated("items", 1, ".acme.inventory.Inventory.ItemsEntry"),
+					repeated("kinds", 2, ".acme.inventory.Inventory.KindsEntry"),
+					repeated("list", 3, ".acme.inventory.Inventory.Item"),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{
+					mapEntry("ItemsEntry",
+						&descriptorpb.FieldDescriptorProto{Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+						&descriptorpb.FieldDescriptorProto{
+							Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+							TypeName: proto.String(".acme.inventory.Inventory.Item"),
+						},
+					),
+					mapEntry("KindsEntry",
+						&descriptorpb.FieldDescriptorProto{Type: descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum()},
+						&descriptorpb.FieldDescriptorProto{
+							Type:     descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum(),
+							TypeName: proto.String(".acme.inventory.Inventory.Kind"),
+						},
+					),
+					{Name: proto.String("Item")},
+				},
+				EnumType: []*descriptorpb.EnumDescriptorProto{{
+					Name:  proto.String("Kind"),
+					Value: []*descriptorpb.EnumValueDescriptorProto{{Name: proto.String("UNKNOWN"), Number: proto.Int32(0)}},
+				}},
+			}},
+		}},
+	}
+
+	m := protokit.ParseCodeGenRequest(req)[0].GetMessage("Inventory")
+	require.Len(t, m.GetMessages(), 3)
+	require.Equal(t, []*protokit.Descriptor{m.GetMessage("Item")}, m.GetMessagesWithoutMapEntries())
+	require.True(t, m.GetMessage("ItemsEntry").IsMapEntry())
+	require.False(t, m.GetMessage("Item").IsMapEntry())
+
+	items := m.GetMessageField("items")
+	require.True(t, items.IsMap())
+	require.Equal(t, descriptorpb.FieldDescriptorProto_TYPE_STRING, items.GetMapKey().GetType())
+	require.Equal(t, m.GetMessage("Item"), items.GetMapValue().GetMessageType())
+
+	kinds := m.GetMessageField("kinds")
+	require.True(t, kinds.IsMap())
+	require.Equal(t, descriptorpb.FieldDescriptorProto_TYPE_INT32, kinds.GetMapKey().GetType())
+	require.Equal(t, m.GetEnum("Kind"), kinds.GetMapValue().GetEnumType())
+
+	list := m.GetMessageField("list")
+	require.False(t, list.IsMap())
+	require.Nil(t, list.GetMapKey())
+	require.Nil(t, list.GetMapValue())
+}
+
 func TestMessageEnums(t *testing.T) {
 	t.Parallel()
 
@@ -205,6 +471,56 @@ func TestMessageExtensions(t *testing.T) {
 	require.Equal(t, "An optional field to be used however you please.", ext.GetComments().String())
 }
 
+func TestFieldTypes(t *testing.T) {
+	t.Parallel()
+
+	proto2, proto3 := setupParserTest(t)
+
+	booking := proto2.GetMessage("Booking")
+	status := proto2.GetMessage("BookingStatus")
+
+	f := booking.GetMessageField("status")
+	require.Equal(t, status, f.GetMessageType())
+	require.Nil(t, f.GetEnumType())
+
+	f = status.GetMessageField("status_code")
+	require.Equal(t, status.GetEnum("StatusCode"), f.GetEnumType())
+	require.Nil(t, f.GetMessageType())
+
+	f = booking.GetMessageField("vehicle_id")
+	require.Nil(t, f.GetMessageType())
+	require.Nil(t, f.GetEnumType())
+
+	// types from imported files are resolved too
+	item := proto3.GetMessage("Item")
+	f = item.GetMessageField("details")
+	require.Equal(t, proto3.GetImports()[0].GetFullName(), f.GetMessageType().GetFullName())
+	require.Equal(t, "todo_import.proto", f.GetMessageType().GetFile().GetName())
+
+	f = item.GetMessageField("created_at")
+	require.Equal(t, "google.protobuf.Timestamp", f.GetMessageType().GetFullName())
+	require.Equal(t, "google/protobuf/timestamp.proto", f.GetMessageType().GetFile().GetName())
+
+	f = item.GetMessageField("completed")
+	require.Equal(t, item.GetEnum("Status"), f.GetEnumType())
+}
+
+func TestExtensionTypes(t *testing.T) {
+	t.Parallel()
+
+	proto2, _ := setupParserTest(t)
+
+	status := proto2.GetMessage("BookingStatus")
+
+	ext := proto2.GetExtensions()[0]
+	require.Equal(t, status, ext.GetExtendedMessage())
+	require.Nil(t, ext.GetMessageType())
+	require.Nil(t, ext.GetEnumType())
+
+	ext = proto2.GetMessage("Booking").GetExtensions()[0]
+	require.Equal(t, status, ext.GetExtendedMessage())
+}
+
 func TestNestedMessages(t *testing.T) {
 	t.Parallel()
 
@@ -1,39 +1,317 @@
 package protokit
 
 import (
+	"cmp"
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"runtime/debug"
+	"syscall"
 
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
 	pluginpb "google.golang.org/protobuf/types/pluginpb"
 )
 
+// ErrPluginPanic is wrapped by the `GeneratorError` reported when a plugin panics
+var ErrPluginPanic = errors.New("plugin panicked")
+
+// A GeneratorError is a problem that should be reported to the user rather than crash the plugin, e.g. an unsupported
+// field type or a bad option value. When `Plugin.Generate` returns an error that wraps a `GeneratorError`,
+// `RunPluginWithIO` writes a response with the error message in `CodeGeneratorResponse.error` and protoc reports it
+// to the user.
+type GeneratorError struct {
+	// Location is the (optional) location of the element that caused the error
+	Location *Location
+	// Err describes the problem
+	Err error
+}
+
+// NewGeneratorError returns a `GeneratorError` for the given location (which can be `nil`). The message is formatted
+// using `fmt.Errorf`, so `%w` can be used to wrap other errors.
+func NewGeneratorError(loc *Location, format string, args ...any) *GeneratorError {
+	return &GeneratorError{Location: loc, Err: fmt.Errorf(format, args...)}
+}
+
+// Error returns the error message prefixed with the location (if any). E.g. `booking.proto:42:3: problem`.
+func (e *GeneratorError) Error() string {
+	if e.Location == nil || e.Location.File == "" {
+		return e.Err.Error()
+	}
+
+	return fmt.Sprintf("%s: %v", e.Location, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *GeneratorError) Unwrap() error { return e.Err }
+
+// panicError returns the generator error reported for a recovered panic. Only the panic value ends up in the response
+// (protoc shows it to users), while the stack trace is written to stderr for the plugin's author. It must be called
+// from the deferred function so the stack trace includes the panic.
+func panicError(loc *Location, r any) error {
+	err := &GeneratorError{Location: loc, Err: fmt.Errorf("%w: %v", ErrPluginPanic, r)}
+	fmt.Fprintf(os.Stderr, "%v\n\n%s", err, debug.Stack())
+
+	return err
+}
+
 // Plugin describes an interface for running protoc code generator plugins
 type Plugin interface {
 	Generate(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error)
 }
 
+// A ParamsPlugin is a `Plugin` that takes parameters. Before `Generate` is called, the plugin parameter is parsed and
+// passed to `SetParams`, so the plugin doesn't need to parse `req.GetParameter()` itself. Use `Params.BindFlags` to
+// populate an options struct. If `SetParams` returns an error, `Generate` isn't called.
+type ParamsPlugin interface {
+	Plugin
+	SetParams(params Params) error
+}
+
+// A ContextPlugin is a `Plugin` that takes a context. `RunPluginContext` cancels the context when the plugin receives
+// SIGINT or SIGTERM, or when the deadline set by the caller expires. The context also carries the request (see
+// `RequestFromContext`), the parsed request (see `RegistryFromContext`) and the parsed parameters (see
+// `ParamsFromContext`), so the plugin doesn't need to parse either again.
+//
+// Long-running plugins should check `ctx.Err()` regularly and return it (wrapped or not) along with whatever they
+// generated so far. The run functions report this as an error response that includes the partial output.
+type ContextPlugin interface {
+	Plugin
+	GenerateContext(ctx context.Context, req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error)
+}
+
+// A FeatureReporter declares the features supported by a `Plugin`. Newer versions of protoc refuse to run
+// plugins on editions files or proto3 `optional` fields unless the response says they're supported. `RunPluginWithIO`
+// stamps these on every response, including error responses: features are added to any the response already sets,
+// while editions set on the response are kept. See `PluginFeatures` for a ready-made implementation.
+type FeatureReporter interface {
+	// SupportedFeatures returns the features (e.g. `FEATURE_PROTO3_OPTIONAL`) the plugin supports
+	SupportedFeatures() []pluginpb.CodeGeneratorResponse_Feature
+	// SupportedEditions returns the range of editions the plugin supports (e.g. `EDITION_PROTO2` to `EDITION_2023`).
+	// Return `EDITION_UNKNOWN` for both if editions aren't supported.
+	SupportedEditions() (minimum, maximum descriptorpb.Edition)
+}
+
+// PluginFeatures implements `FeatureReporter` and is meant to be embedded in plugins. E.g.
+//
+//	type plugin struct {
+//		protokit.PluginFeatures
+//	}
+//
+//	p := &plugin{PluginFeatures: protokit.PluginFeatures{
+//		Features:       []pluginpb.CodeGeneratorResponse_Feature{pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL},
+//		MinimumEdition: descriptorpb.Edition_EDITION_PROTO2,
+//		MaximumEdition: descriptorpb.Edition_EDITION_2024,
+//	}}
+type PluginFeatures struct {
+	Features       []pluginpb.CodeGeneratorResponse_Feature
+	MinimumEdition descriptorpb.Edition
+	MaximumEdition descriptorpb.Edition
+}
+
+// SupportedFeatures implements `FeatureReporter`
+func (f PluginFeatures) SupportedFeatures() []pluginpb.CodeGeneratorResponse_Feature {
+	return f.Features
+}
+
+// SupportedEditions implements `FeatureReporter`
+func (f PluginFeatures) SupportedEditions() (minimum, maximum descriptorpb.Edition) {
+	return f.MinimumEdition, f.MaximumEdition
+}
+
 // RunPlugin runs the supplied plugin by reading input from stdin and generating output to stdout.
+//
+// When the arguments include one of the flags owned by standalone mode (`--descriptor_set_in`, `--proto_path`, `--file`
+// or `--out`), it runs in standalone mode instead (see `RunPluginStandalone`). Any other arguments are ignored, so
+// plugins can still take their own flags. When stdin is a terminal (i.e. the plugin was run by hand without those
+// flags), the standalone usage is printed and an error wrapping `ErrStandaloneUsage` is returned rather than waiting
+// for a request.
 func RunPlugin(p Plugin) error {
+	if args, ok := standaloneArgs(os.Args[1:], os.Stdin); ok {
+		return RunPluginStandalone(context.Background(), p, args)
+	}
+
 	return RunPluginWithIO(p, os.Stdin, os.Stdout)
 }
 
 // RunPluginWithIO runs the supplied plugin using the supplied reader and writer for IO.
+//
+// Errors that wrap a `GeneratorError` (including invalid parameters and panics) are written to the response's `error`
+// field, in which case `nil` is returned. Any other error is returned as is and nothing is written.
+//
+// If the plugin implements `FeatureReporter`, its supported features and editions are set on the response. Requests and
+// responses can be captured for debugging using `CaptureEnvVar`.
 func RunPluginWithIO(p Plugin, r io.Reader, w io.Writer) error {
+	return RunPluginContextWithIO(context.Background(), p, r, w)
+}
+
+// RunPluginContext runs the supplied plugin by reading input from stdin and generating output to stdout. The context
+// passed to the plugin is canceled when the process receives SIGINT or SIGTERM, or when ctx is done. Use
+// `context.WithTimeout` to set a deadline. E.g.
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+//	defer cancel()
+//
+//	if err := protokit.RunPluginContext(ctx, p); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// Once the context is canceled, the default signal behavior is restored, so a second signal terminates the process
+// even if the plugin doesn't return. Like `RunPlugin`, it runs in standalone mode when given the standalone flags, and
+// prints the usage when stdin is a terminal.
+func RunPluginContext(ctx context.Context, p Plugin) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if args, ok := standaloneArgs(os.Args[1:], os.Stdin); ok {
+		return RunPluginStandalone(ctx, p, args)
+	}
+
+	return RunPluginContextWithIO(ctx, p, os.Stdin, os.Stdout)
+}
+
+// RunPluginContextWithIO is like `RunPluginWithIO`, but passes ctx to plugins that implement `ContextPlugin`.
+//
+// If the plugin returns an error caused by the context being canceled or its deadline expiring, the error is written
+// to the response along with the files the plugin returned (if any) and `nil` is returned.
+func RunPluginContextWithIO(ctx context.Context, p Plugin, r io.Reader, w io.Writer) error {
 	req, err := readRequest(r)
 	if err != nil {
 		return err
 	}
 
-	resp, err := p.Generate(req)
+	reqPath, respPath := capturePaths()
+	if reqPath != "" {
+		if err := writeCapture(reqPath, req, req.GetProtoFile()); err != nil {
+			return fmt.Errorf("capturing request: %w", err)
+		}
+	}
+
+	resp, err := runRequest(ctx, p, req)
 	if err != nil {
 		return err
 	}
 
+	if respPath != "" {
+		if err := writeCapture(respPath, cmp.Or(resp, new(pluginpb.CodeGeneratorResponse)), nil); err != nil {
+			return fmt.Errorf("capturing response: %w", err)
+		}
+	}
+
 	return writeResponse(w, resp)
 }
 
+// runRequest runs the plugin and returns the response to write. Reportable errors are set on the response, while any
+// other error is returned.
+func runRequest(
+	ctx context.Context,
+	p Plugin,
+	req *pluginpb.CodeGeneratorRequest,
+) (*pluginpb.CodeGeneratorResponse, error) {
+	resp, err := generate(ContextWithRequest(ctx, req), p, req)
+	if err != nil {
+		if !isReportable(err) {
+			return nil, err
+		}
+
+		if resp == nil {
+			resp = new(pluginpb.CodeGeneratorResponse)
+		}
+
+		resp.Error = proto.String(err.Error())
+	}
+
+	if fr, ok := p.(FeatureReporter); ok {
+		if resp == nil {
+			resp = new(pluginpb.CodeGeneratorResponse)
+		}
+
+		setSupportedFeatures(resp, fr)
+	}
+
+	return resp, nil
+}
+
+// isReportable returns whether err should be written to the response rather than returned
+func isReportable(err error) bool {
+	var genErr *GeneratorError
+	return errors.As(err, &genErr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// setSupportedFeatures adds the features declared by the plugin to the response. Features are ORed with the ones
+// already set on the response (so a response can't turn a declared feature off), while editions set on the response
+// take precedence over the declared ones. Since protoc ignores the edition range unless `FEATURE_SUPPORTS_EDITIONS` is
+// set, it's added automatically when a maximum edition is declared.
+func setSupportedFeatures(resp *pluginpb.CodeGeneratorResponse, fr FeatureReporter) {
+	features := resp.GetSupportedFeatures()
+	for _, f := range fr.SupportedFeatures() {
+		features |= uint64(f)
+	}
+
+	minimum, maximum := fr.SupportedEditions()
+	if maximum != descriptorpb.Edition_EDITION_UNKNOWN {
+		features |= uint64(pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS)
+
+		if resp.MinimumEdition == nil {
+			resp.MinimumEdition = proto.Int32(int32(minimum))
+		}
+
+		if resp.MaximumEdition == nil {
+			resp.MaximumEdition = proto.Int32(int32(maximum))
+		}
+	}
+
+	if features != 0 {
+		resp.SupportedFeatures = proto.Uint64(features)
+	}
+}
+
+// generate runs the plugin, converting panics into generator errors. The parsed parameters are added to the context
+// when the parameter is valid. Invalid parameters are only an error for a `ParamsPlugin`, since other plugins may use
+// their own format. The request is parsed into a `Registry` once for a `ContextPlugin`, which is the only kind of
+// plugin that can get it from the context.
+func generate(
+	ctx context.Context,
+	p Plugin,
+	req *pluginpb.CodeGeneratorRequest,
+) (resp *pluginpb.CodeGeneratorResponse, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			resp = nil
+			err = panicError(nil, r)
+		}
+	}()
+
+	params, paramsErr := ParseParams(req.GetParameter())
+	if paramsErr == nil {
+		ctx = ContextWithParams(ctx, params)
+	}
+
+	if pp, ok := p.(ParamsPlugin); ok {
+		if paramsErr != nil {
+			return nil, &GeneratorError{Err: paramsErr}
+		}
+
+		if err := pp.SetParams(params); err != nil {
+			return nil, &GeneratorError{Err: err}
+		}
+	}
+
+	if cp, ok := p.(ContextPlugin); ok {
+		return cp.GenerateContext(ContextWithRegistry(ctx, NewRegistry(req)), req)
+	}
+
+	return p.Generate(req)
+}
+
 func readRequest(r io.Reader) (*pluginpb.CodeGeneratorRequest, error) {
 	data, err := io.ReadAll(r)
 	if err != nil {
@@ -2,13 +2,19 @@ package protokit_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/pseudomuto/protokit"
 	"github.com/pseudomuto/protokit/utils"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
 	pluginpb "google.golang.org/protobuf/types/pluginpb"
 )
 
@@ -94,3 +100,302 @@ func (op *OkPlugin) Generate(r *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGe
 
 	return resp, nil
 }
+
+func TestRunPluginParams(t *testing.T) {
+	t.Parallel()
+
+	fds, err := utils.LoadDescriptorSet("fixtures", "fileset.pb")
+	require.NoError(t, err)
+
+	req := utils.CreateGenRequest(fds, "booking.proto")
+	req.Parameter = proto.String("suffix=.txt")
+	data, err := proto.Marshal(req)
+	require.NoError(t, err)
+
+	p := newParamsPlugin()
+	require.NoError(t, protokit.RunPluginWithIO(p, bytes.NewBuffer(data), new(bytes.Buffer)))
+	require.Equal(t, ".txt", p.suffix)
+	require.Equal(t, "booking.proto.txt", p.generated)
+
+	// unknown parameters are reported to the user
+	req.Parameter = proto.String("suffix=.txt,whodis")
+	p = newParamsPlugin()
+	resp := runPlugin(t, p, req)
+	require.Equal(t, "unknown parameter: whodis", resp.GetError())
+	require.Empty(t, p.generated)
+}
+
+type ParamsPlugin struct {
+	flags     *flag.FlagSet
+	suffix    string
+	generated string
+}
+
+func newParamsPlugin() *ParamsPlugin {
+	p := &ParamsPlugin{flags: flag.NewFlagSet("params", flag.ContinueOnError)}
+	p.flags.StringVar(&p.suffix, "suffix", ".out", "")
+
+	return p
+}
+
+func (p *ParamsPlugin) SetParams(params protokit.Params) error { return params.BindFlags(p.flags) }
+
+func (p *ParamsPlugin) Generate(r *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+	p.generated = r.GetFileToGenerate()[0] + p.suffix
+	return new(pluginpb.CodeGeneratorResponse), nil
+}
+
+func TestRunPluginGeneratorErrorResponse(t *testing.T) {
+	t.Parallel()
+
+	fds, err := utils.LoadDescriptorSet("fixtures", "fileset.pb")
+	require.NoError(t, err)
+
+	req := utils.CreateGenRequest(fds, "booking.proto")
+	booking := protokit.ParseCodeGenRequest(req)[0]
+
+	p := &FuncPlugin{generate: func(*pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+		field := booking.GetMessage("Booking").GetMessageField("status")
+		return nil, protokit.NewGeneratorError(field.GetLocation(), "unsupported field type: %s", field.GetType())
+	}}
+
+	resp := runPlugin(t, p, req)
+	require.Equal(t, "booking.proto:71:3: unsupported field type: TYPE_MESSAGE", resp.GetError())
+	require.Empty(t, resp.GetFile())
+
+	// all joined and wrapped errors are reported
+	p.generate = func(*pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+		return nil, fmt.Errorf("generating: %w", errors.Join(
+			protokit.NewGeneratorError(booking.GetService("BookingService").GetLocation(), "no streaming"),
+			protokit.NewGeneratorError(nil, "bad things: %w", io.EOF),
+		))
+	}
+
+	resp = runPlugin(t, p, req)
+	require.Equal(t, "generating: booking.proto:17:1: no streaming\nbad things: EOF", resp.GetError())
+}
+
+func TestRunPluginPanic(t *testing.T) {
+	t.Parallel()
+
+	fds, err := utils.LoadDescriptorSet("fixtures", "fileset.pb")
+	require.NoError(t, err)
+
+	req := utils.CreateGenRequest(fds, "booking.proto")
+	p := &FuncPlugin{generate: func(*pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+		panic("boom")
+	}}
+
+	resp := runPlugin(t, p, req)
+	require.Equal(t, "plugin panicked: boom", resp.GetError())
+}
+
+func TestGeneratorError(t *testing.T) {
+	t.Parallel()
+
+	err := protokit.NewGeneratorError(nil, "failed: %w", io.EOF)
+	require.EqualError(t, err, "failed: EOF")
+	require.True(t, errors.Is(err, io.EOF))
+
+	err.Location = &protokit.Location{File: "todo.proto"}
+	require.EqualError(t, err, "todo.proto: failed: EOF")
+
+	err.Location = &protokit.Location{File: "todo.proto", StartLine: 4, StartColumn: 2}
+	require.EqualError(t, err, "todo.proto:4:2: failed: EOF")
+}
+
+func TestRunPluginContext(t *testing.T) {
+	t.Parallel()
+
+	req := fixtureRequest(t, "booking.proto")
+	req.Parameter = proto.String("mode=fast,verbose")
+
+	p := &ContextPlugin{generate: func(ctx context.Context, r *pluginpb.CodeGeneratorRequest) (
+		*pluginpb.CodeGeneratorResponse,
+		error,
+	) {
+		ctxReq, ok := protokit.RequestFromContext(ctx)
+		require.True(t, ok)
+		require.True(t, proto.Equal(r, ctxReq))
+
+		// the request is parsed before the plugin runs
+		reg, ok := protokit.RegistryFromContext(ctx)
+		require.True(t, ok)
+		require.Equal(t, "booking.proto", reg.FilesToGenerate()[0].GetName())
+
+		params, ok := protokit.ParamsFromContext(ctx)
+		require.True(t, ok)
+		require.Equal(t, "fast", params.Get("mode"))
+		require.True(t, params.Has("verbose"))
+
+		return &pluginpb.CodeGeneratorResponse{File: []*pluginpb.CodeGeneratorResponse_File{
+			{Name: proto.String("out.txt")},
+		}}, nil
+	}}
+
+	resp := runPlugin(t, p, req)
+	require.Empty(t, resp.GetError())
+	require.Len(t, resp.GetFile(), 1)
+}
+
+func TestRunPluginContextInvalidParams(t *testing.T) {
+	t.Parallel()
+
+	req := fixtureRequest(t, "booking.proto")
+	req.Parameter = proto.String(`some "custom" format`)
+
+	// only a ParamsPlugin requires a valid parameter
+	p := &ContextPlugin{generate: func(ctx context.Context, r *pluginpb.CodeGeneratorRequest) (
+		*pluginpb.CodeGeneratorResponse,
+		error,
+	) {
+		_, ok := protokit.ParamsFromContext(ctx)
+		require.False(t, ok)
+		require.Equal(t, `some "custom" format`, r.GetParameter())
+
+		return new(pluginpb.CodeGeneratorResponse), nil
+	}}
+
+	resp := runPlugin(t, p, req)
+	require.Empty(t, resp.GetError())
+}
+
+func TestRunPluginContextDeadline(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	p := &ContextPlugin{generate: func(ctx context.Context, _ *pluginpb.CodeGeneratorRequest) (
+		*pluginpb.CodeGeneratorResponse,
+		error,
+	) {
+		<-ctx.Done()
+
+		return &pluginpb.CodeGeneratorResponse{File: []*pluginpb.CodeGeneratorResponse_File{
+			{Name: proto.String("partial.txt")},
+		}}, fmt.Errorf("stopped early: %w", ctx.Err())
+	}}
+
+	data, err := proto.Marshal(fixtureRequest(t, "booking.proto"))
+	require.NoError(t, err)
+
+	out := new(bytes.Buffer)
+	require.NoError(t, protokit.RunPluginContextWithIO(ctx, p, bytes.NewBuffer(data), out))
+
+	resp := new(pluginpb.CodeGeneratorResponse)
+	require.NoError(t, proto.Unmarshal(out.Bytes(), resp))
+	require.Equal(t, "stopped early: context deadline exceeded", resp.GetError())
+	require.Len(t, resp.GetFile(), 1)
+	require.Equal(t, "partial.txt", resp.GetFile()[0].GetName())
+}
+
+// runPlugin runs the plugin with the given request and returns the response written by it
+func runPlugin(t *testing.T, p protokit.Plugin, req *pluginpb.CodeGeneratorRequest) *pluginpb.CodeGeneratorResponse {
+	t.Helper()
+
+	data, err := proto.Marshal(req)
+	require.NoError(t, err)
+
+	out := new(bytes.Buffer)
+	require.NoError(t, protokit.RunPluginWithIO(p, bytes.NewBuffer(data), out))
+
+	resp := new(pluginpb.CodeGeneratorResponse)
+	require.NoError(t, proto.Unmarshal(out.Bytes(), resp))
+
+	return resp
+}
+
+type FuncPlugin struct {
+	generate func(*pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error)
+}
+
+func (p *FuncPlugin) Generate(r *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+	return p.generate(r)
+}
+
+type ContextPlugin struct {
+	generate func(context.Context, *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error)
+}
+
+func (p *ContextPlugin) Generate(r *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+	return p.GenerateContext(context.Background(), r)
+}
+
+func (p *ContextPlugin) GenerateContext(
+	ctx context.Context,
+	r *pluginpb.CodeGeneratorRequest,
+) (*pluginpb.CodeGeneratorResponse, error) {
+	return p.generate(ctx, r)
+}
+
+func TestRunPluginSupportedFeatures(t *testing.T) {
+	t.Parallel()
+
+	fds, err := utils.LoadDescriptorSet("fixtures", "fileset.pb")
+	require.NoError(t, err)
+
+	req := utils.CreateGenRequest(fds, "edition2023.proto")
+	features := protokit.PluginFeatures{
+		Features:       []pluginpb.CodeGeneratorResponse_Feature{pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL},
+		MinimumEdition: descriptorpb.Edition_EDITION_PROTO2,
+		MaximumEdition: descriptorpb.Edition_EDITION_2024,
+	}
+
+	p := &FeaturesPlugin{PluginFeatures: features, FuncPlugin: FuncPlugin{
+		generate: func(*pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+			return new(pluginpb.CodeGeneratorResponse), nil
+		},
+	}}
+
+	resp := runPlugin(t, p, req)
+	require.Equal(t, uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL|
+		pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS), resp.GetSupportedFeatures())
+	require.Equal(t, int32(descriptorpb.Edition_EDITION_PROTO2), resp.GetMinimumEdition())
+	require.Equal(t, int32(descriptorpb.Edition_EDITION_2024), resp.GetMaximumEdition())
+
+	// error responses are stamped too
+	p.generate = func(*pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+		return nil, protokit.NewGeneratorError(nil, "nope")
+	}
+
+	resp = runPlugin(t, p, req)
+	require.Equal(t, "nope", resp.GetError())
+	require.Equal(t, int32(descriptorpb.Edition_EDITION_2024), resp.GetMaximumEdition())
+
+	// editions set on the response take precedence, while features are combined with the declared ones
+	p.generate = func(*pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+		return &pluginpb.CodeGeneratorResponse{
+			SupportedFeatures: proto.Uint64(uint64(pluginpb.CodeGeneratorResponse_FEATURE_NONE)),
+			MaximumEdition:    proto.Int32(int32(descriptorpb.Edition_EDITION_2023)),
+		}, nil
+	}
+
+	resp = runPlugin(t, p, req)
+	require.Equal(t, int32(descriptorpb.Edition_EDITION_PROTO2), resp.GetMinimumEdition())
+	require.Equal(t, int32(descriptorpb.Edition_EDITION_2023), resp.GetMaximumEdition())
+	require.Equal(t, uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL|
+		pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS), resp.GetSupportedFeatures())
+}
+
+func TestRunPluginNoEditions(t *testing.T) {
+	t.Parallel()
+
+	fds, err := utils.LoadDescriptorSet("fixtures", "fileset.pb")
+	require.NoError(t, err)
+
+	p := &FeaturesPlugin{FuncPlugin: FuncPlugin{
+		generate: func(*pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) { return nil, nil },
+	}}
+	p.Features = []pluginpb.CodeGeneratorResponse_Feature{pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL}
+
+	resp := runPlugin(t, p, utils.CreateGenRequest(fds, "todo.proto"))
+	require.Equal(t, uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL), resp.GetSupportedFeatures())
+	require.Nil(t, resp.MinimumEdition)
+	require.Nil(t, resp.MaximumEdition)
+}
+
+type FeaturesPlugin struct {
+	FuncPlugin
+	protokit.PluginFeatures
+}
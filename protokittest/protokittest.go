@@ -0,0 +1,253 @@
+// Package protokittest provides helpers for testing protoc plugins built with protokit.
+//
+// Plugins are run against a `FileDescriptorSet` (e.g. one generated with `protoc --descriptor_set_out`) and their
+// output is compared against golden files. Run the tests with `PROTOKIT_UPDATE_GOLDEN=1` (see `UpdateEnvVar`) to
+// (re)write the golden files after changing the generated output. For example:
+//
+//	func TestGenerate(t *testing.T) {
+//		fds, err := utils.LoadDescriptorSet("testdata", "fileset.pb")
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//
+//		resp := protokittest.Run(t, new(plugin), fds, "booking.proto", "todo.proto")
+//		protokittest.AssertGolden(t, resp, filepath.Join("testdata", "golden"))
+//	}
+package protokittest
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/pseudomuto/protokit"
+	"github.com/pseudomuto/protokit/utils"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
+)
+
+// UpdateEnvVar is the environment variable that makes `AssertGolden` update the golden files instead of comparing them.
+// It's set to a boolean (as parsed by `strconv.ParseBool`), e.g.
+//
+//	PROTOKIT_UPDATE_GOLDEN=1 go test ./...
+const UpdateEnvVar = "PROTOKIT_UPDATE_GOLDEN"
+
+// Run runs the plugin against the given files from the descriptor set and returns the response. The test fails if
+// any of the files aren't in the set.
+func Run(
+	t testing.TB,
+	p protokit.Plugin,
+	fds *descriptorpb.FileDescriptorSet,
+	files ...string,
+) *pluginpb.CodeGeneratorResponse {
+	t.Helper()
+
+	for _, name := range files {
+		if !slices.ContainsFunc(fds.GetFile(), func(f *descriptorpb.FileDescriptorProto) bool {
+			return f.GetName() == name
+		}) {
+			t.Fatalf("%s not found in the descriptor set", name)
+		}
+	}
+
+	return RunRequest(t, p, utils.CreateGenRequest(fds, files...))
+}
+
+// RunRequest runs the plugin with the given request (e.g. to set the parameter) and returns the response. The plugin
+// is run the same way protoc does, i.e. using `protokit.RunPluginWithIO`, so errors that protoc would report are
+// set on the response. Any other error fails the test.
+func RunRequest(t testing.TB, p protokit.Plugin, req *pluginpb.CodeGeneratorRequest) *pluginpb.CodeGeneratorResponse {
+	t.Helper()
+
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshaling request: %v", err)
+	}
+
+	out := new(bytes.Buffer)
+	if err := protokit.RunPluginWithIO(p, bytes.NewReader(data), out); err != nil {
+		t.Fatalf("running plugin: %v", err)
+	}
+
+	resp := new(pluginpb.CodeGeneratorResponse)
+	if err := proto.Unmarshal(out.Bytes(), resp); err != nil {
+		t.Fatalf("unmarshaling response: %v", err)
+	}
+
+	return resp
+}
+
+// AssertGolden compares the files in the response against the golden files in dir. Each output file is compared to
+// the file with the same name (relative to dir). The content for insertion points is compared to `name@point`.
+//
+// Differences are reported as unified diffs. Golden files that weren't generated are reported too, so renamed or
+// removed outputs aren't missed. When `UpdateEnvVar` is set, the generated files are written instead. Nothing is
+// deleted: golden files that weren't generated are logged so they can be removed by hand.
+func AssertGolden(t testing.TB, resp *pluginpb.CodeGeneratorResponse, dir string) {
+	t.Helper()
+
+	AssertNoError(t, resp)
+
+	outputs, names := goldenOutputs(resp)
+	existing := goldenFiles(t, dir)
+
+	if update, _ := strconv.ParseBool(os.Getenv(UpdateEnvVar)); update {
+		updateGolden(t, dir, outputs, names, existing)
+		return
+	}
+
+	for _, name := range names {
+		want, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("%s was generated, but reading the golden file failed: %v (set %s to create it)",
+				name, err, UpdateEnvVar)
+			continue
+		}
+
+		if got := outputs[name]; got != string(want) {
+			t.Errorf("%s doesn't match the golden file (set %s to update it):\n%s",
+				name, UpdateEnvVar, diff(name, string(want), got))
+		}
+	}
+
+	for _, name := range existing {
+		if _, ok := outputs[name]; !ok {
+			t.Errorf("%s has a golden file but wasn't generated", name)
+		}
+	}
+}
+
+// AssertNoError fails the test if the response has an error
+func AssertNoError(t testing.TB, resp *pluginpb.CodeGeneratorResponse) {
+	t.Helper()
+
+	if resp.Error != nil {
+		t.Fatalf("expected no error, got: %s", resp.GetError())
+	}
+}
+
+// AssertError checks that the response has the given error. Since multiple errors are joined with newlines, want can
+// span multiple lines.
+func AssertError(t testing.TB, resp *pluginpb.CodeGeneratorResponse, want string) {
+	t.Helper()
+
+	if resp.Error == nil {
+		t.Errorf("expected error %q, got none", want)
+		return
+	}
+
+	if got := resp.GetError(); got != want {
+		t.Errorf("unexpected error:\n%s", diff("error", want, got))
+	}
+}
+
+// AssertErrorContains checks that the response has an error that contains all of the given strings, e.g. the
+// locations and messages of the expected problems
+func AssertErrorContains(t testing.TB, resp *pluginpb.CodeGeneratorResponse, substrs ...string) {
+	t.Helper()
+
+	if resp.Error == nil {
+		t.Errorf("expected an error containing %q, got none", substrs)
+		return
+	}
+
+	for _, s := range substrs {
+		if !strings.Contains(resp.GetError(), s) {
+			t.Errorf("expected the error to contain %q, got: %s", s, resp.GetError())
+		}
+	}
+}
+
+// goldenOutputs returns the content for each golden file name, along with the names in the order they were generated
+func goldenOutputs(resp *pluginpb.CodeGeneratorResponse) (map[string]string, []string) {
+	outputs := make(map[string]string)
+	names := make([]string, 0, len(resp.GetFile()))
+
+	for _, f := range resp.GetFile() {
+		name := f.GetName()
+		if f.InsertionPoint != nil {
+			name += "@" + f.GetInsertionPoint()
+		}
+
+		if _, ok := outputs[name]; !ok {
+			names = append(names, name)
+		}
+
+		// multiple insertions at the same point are compared as one
+		outputs[name] += f.GetContent()
+	}
+
+	return outputs, names
+}
+
+// goldenFiles returns the names of all files in dir, relative to dir and using forward slashes like protoc
+func goldenFiles(t testing.TB, dir string) []string {
+	t.Helper()
+
+	var names []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("reading golden files: %v", err)
+	}
+
+	return names
+}
+
+func updateGolden(t testing.TB, dir string, outputs map[string]string, names, existing []string) {
+	t.Helper()
+
+	for _, name := range existing {
+		if _, ok := outputs[name]; !ok {
+			t.Logf("%s has a golden file but wasn't generated, remove it if it's no longer needed", name)
+		}
+	}
+
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatalf("updating golden file: %v", err)
+		}
+
+		//nolint:gosec // golden files are checked in like any other source file
+		if err := os.WriteFile(path, []byte(outputs[name]), 0o644); err != nil {
+			t.Fatalf("updating golden file: %v", err)
+		}
+	}
+}
+
+// diff returns a unified diff between the expected and actual content
+func diff(name, want, got string) string {
+	d, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(want),
+		B:        difflib.SplitLines(got),
+		FromFile: name + " (want)",
+		ToFile:   name + " (got)",
+		Context:  3,
+	})
+
+	if err != nil {
+		return "want: " + strconv.Quote(want) + "\ngot:  " + strconv.Quote(got)
+	}
+
+	return d
+}
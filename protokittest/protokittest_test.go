@@ -0,0 +1,173 @@
+package protokittest_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/pseudomuto/protokit"
+	"github.com/pseudomuto/protokit/protokittest"
+	"github.com/pseudomuto/protokit/utils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestAssertGolden(t *testing.T) {
+	t.Parallel()
+
+	resp := protokittest.Run(t, newPlugin(), loadFixtures(t), "booking.proto", "todo.proto")
+	protokittest.AssertGolden(t, resp, filepath.Join("testdata", "golden"))
+}
+
+func TestAssertGoldenMismatch(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o750))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	write("booking.proto.txt", "package com.pseudomuto.protokit.v1\n\nmessage BookingStatus\nmessage Thing\n")
+	write("all.txt", "files:\n")
+	write("old/removed.txt", "gone")
+
+	resp := protokittest.Run(t, newPlugin(), loadFixtures(t), "booking.proto")
+	tb := run(func(tb testing.TB) { protokittest.AssertGolden(tb, resp, dir) })
+
+	require.False(t, tb.fatal)
+	require.Len(t, tb.errors, 4)
+	require.Contains(t, tb.errors[0], "booking.proto.txt doesn't match the golden file")
+	require.Contains(t, tb.errors[0], "-message Thing\n+message Booking\n")
+	require.Contains(t, tb.errors[1], "all.txt@files was generated, but reading the golden file failed")
+	require.Contains(t, tb.errors[2], "all.txt doesn't match the golden file")
+	require.Contains(t, tb.errors[2], "@@ -1,2 +1 @@\n files:\n-\n")
+	require.Equal(t, "old/removed.txt has a golden file but wasn't generated", tb.errors[3])
+}
+
+//nolint:paralleltest // sets the update environment variable
+func TestAssertGoldenUpdate(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "old"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "booking.proto.txt"), []byte("outdated"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old", "removed.txt"), []byte("gone"), 0o600))
+
+	resp := protokittest.Run(t, newPlugin(), loadFixtures(t), "booking.proto")
+
+	t.Setenv(protokittest.UpdateEnvVar, "1")
+	protokittest.AssertGolden(t, resp, dir)
+
+	// the generated files are written, but files that weren't generated are kept
+	data, err := os.ReadFile(filepath.Join(dir, "booking.proto.txt"))
+	require.NoError(t, err)
+	require.Equal(t, resp.GetFile()[0].GetContent(), string(data))
+	require.FileExists(t, filepath.Join(dir, "old", "removed.txt"))
+
+	t.Setenv(protokittest.UpdateEnvVar, "false")
+	tb := run(func(tb testing.TB) { protokittest.AssertGolden(tb, resp, dir) })
+	require.Equal(t, []string{"old/removed.txt has a golden file but wasn't generated"}, tb.errors)
+}
+
+func TestRunRequest(t *testing.T) {
+	t.Parallel()
+
+	req := utils.CreateGenRequest(loadFixtures(t), "todo.proto")
+	req.Parameter = proto.String("fail=todo.proto")
+
+	resp := protokittest.RunRequest(t, newPlugin(), req)
+	protokittest.AssertError(t, resp, "todo.proto:2:1: failing as requested")
+	protokittest.AssertErrorContains(t, resp, "todo.proto:2:1", "as requested")
+
+	tb := run(func(tb testing.TB) { protokittest.AssertGolden(tb, resp, t.TempDir()) })
+	require.True(t, tb.fatal)
+	require.Equal(t, []string{"expected no error, got: todo.proto:2:1: failing as requested"}, tb.errors)
+
+	tb = run(func(tb testing.TB) {
+		protokittest.AssertError(tb, resp, "nope")
+		protokittest.AssertErrorContains(tb, resp, "todo.proto", "nope")
+	})
+	require.Len(t, tb.errors, 2)
+	require.Contains(t, tb.errors[0], "-nope\n+todo.proto:2:1: failing as requested\n")
+	require.Contains(t, tb.errors[1], `expected the error to contain "nope"`)
+
+	tb = run(func(tb testing.TB) { protokittest.Run(tb, newPlugin(), loadFixtures(t), "missing.proto") })
+	require.True(t, tb.fatal)
+	require.Equal(t, []string{"missing.proto not found in the descriptor set"}, tb.errors)
+}
+
+// newPlugin returns a plugin that lists the messages in each file and inserts the file names into all.txt. The files
+// named by the fail parameter fail.
+func newPlugin() protokit.Plugin {
+	return protokit.NewFilePlugin(protokit.FileGeneratorFunc(
+		func(ctx context.Context, fd *protokit.FileDescriptor) ([]*protokit.File, error) {
+			params, _ := protokit.ParamsFromContext(ctx)
+			if params.Get("fail") == fd.GetName() {
+				return nil, protokit.NewGeneratorError(fd.GetLocation(), "failing as requested")
+			}
+
+			b := new(strings.Builder)
+			fmt.Fprintf(b, "package %s\n\n", fd.GetPackage())
+			for _, msg := range fd.GetMessages() {
+				fmt.Fprintf(b, "message %s\n", msg.GetName())
+			}
+
+			files := []*protokit.File{
+				{Name: proto.String(fd.GetName() + ".txt"), Content: proto.String(b.String())},
+				{Name: proto.String("all.txt"), InsertionPoint: proto.String("files"), Content: proto.String(fd.GetName() + "\n")},
+			}
+
+			if fd.GetName() == "booking.proto" {
+				files = append(files, &protokit.File{Name: proto.String("all.txt"), Content: proto.String("files:")})
+			}
+
+			return files, nil
+		},
+	))
+}
+
+func loadFixtures(t *testing.T) *descriptorpb.FileDescriptorSet {
+	t.Helper()
+
+	fds, err := utils.LoadDescriptorSet("..", "fixtures", "fileset.pb")
+	require.NoError(t, err)
+
+	return fds
+}
+
+// fakeTB records failures instead of failing the test
+type fakeTB struct {
+	testing.TB
+	errors []string
+	fatal  bool
+}
+
+func (tb *fakeTB) Helper() {}
+
+func (tb *fakeTB) Errorf(format string, args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func (tb *fakeTB) Fatalf(format string, args ...any) {
+	tb.Errorf(format, args...)
+	tb.fatal = true
+	runtime.Goexit()
+}
+
+// run calls f with a fakeTB and waits for it to return (or call Fatalf)
+func run(f func(testing.TB)) *fakeTB {
+	tb := new(fakeTB)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		f(tb)
+	}()
+
+	<-done
+	return tb
+}
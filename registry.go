@@ -0,0 +1,167 @@
+package protokit
+
+import (
+	"strings"
+
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
+)
+
+// A Registry holds every file in a `CodeGeneratorRequest`, including the ones that aren't being generated, and allows
+// looking up descriptors by their fully-qualified names.
+//
+// Names can be passed with or without a leading dot. E.g. both `.com.pseudomuto.protokit.v1.Booking` and
+// `com.pseudomuto.protokit.v1.Booking` will find the `Booking` message. When a name is defined more than once (only
+// possible in malformed requests), the first definition wins.
+type Registry struct {
+	files           []*FileDescriptor
+	filesByName     map[string]*FileDescriptor
+	filesToGenerate []*FileDescriptor
+
+	messages   map[string]*Descriptor
+	enums      map[string]*EnumDescriptor
+	services   map[string]*ServiceDescriptor
+	methods    map[string]*MethodDescriptor
+	extensions map[string]*ExtensionDescriptor
+}
+
+// NewRegistry parses the given request and returns a registry containing all of its files. The files to generate are
+// available through `FilesToGenerate` and are the same as the ones returned by `ParseCodeGenRequest`.
+func NewRegistry(req *pluginpb.CodeGeneratorRequest) *Registry {
+	return parseRequest(req)
+}
+
+func newRegistry() *Registry {
+	return &Registry{
+		filesByName: make(map[string]*FileDescriptor),
+		messages:    make(map[string]*Descriptor),
+		enums:       make(map[string]*EnumDescriptor),
+		services:    make(map[string]*ServiceDescriptor),
+		methods:     make(map[string]*MethodDescriptor),
+		extensions:  make(map[string]*ExtensionDescriptor),
+	}
+}
+
+// Files returns every file in the request in topological order (each file comes after the files it imports)
+func (r *Registry) Files() []*FileDescriptor { return r.files }
+
+// FilesToGenerate returns the files listed in `req.FileToGenerate` (in the same order)
+func (r *Registry) FilesToGenerate() []*FileDescriptor { return r.filesToGenerate }
+
+// FindFile returns the file with the specified name (returns `nil` if not found)
+func (r *Registry) FindFile(name string) *FileDescriptor { return r.filesByName[name] }
+
+// FindMessage returns the message with the specified fully-qualified name (returns `nil` if not found)
+func (r *Registry) FindMessage(name string) *Descriptor { return r.messages[qualifiedName(name)] }
+
+// FindEnum returns the enum with the specified fully-qualified name (returns `nil` if not found)
+func (r *Registry) FindEnum(name string) *EnumDescriptor { return r.enums[qualifiedName(name)] }
+
+// FindService returns the service with the specified fully-qualified name (returns `nil` if not found)
+func (r *Registry) FindService(name string) *ServiceDescriptor {
+	return r.services[qualifiedName(name)]
+}
+
+// FindMethod returns the method with the specified fully-qualified name, e.g. `.pkg.Service.Method` (returns `nil` if
+// not found)
+func (r *Registry) FindMethod(name string) *MethodDescriptor { return r.methods[qualifiedName(name)] }
+
+// FindExtension returns the extension with the specified name (returns `nil` if not found). The name can either be the
+// extension's fully-qualified name as defined by protobuf (the scope it's declared in, e.g. `.pkg.country`), which is
+// also the key used in `OptionExtensions`, or its `FullName` (which is based on the extendee, e.g.
+// `.pkg.BookingStatus.country`).
+func (r *Registry) FindExtension(name string) *ExtensionDescriptor {
+	return r.extensions[qualifiedName(name)]
+}
+
+// qualifiedName returns the name with a leading dot
+func qualifiedName(name string) string { return "." + strings.TrimPrefix(name, ".") }
+
+func (r *Registry) addFile(f *FileDescriptor) {
+	f.registry = r
+	r.filesByName[f.GetName()] = f
+
+	scope := qualifiedName(f.GetPackage())
+	if f.GetPackage() == "" {
+		scope = ""
+	}
+
+	r.addEnums(f.GetEnums())
+	r.addExtensions(scope, f.GetExtensions())
+	r.addMessages(f.GetMessages())
+
+	for _, s := range f.GetServices() {
+		addSymbol(r.services, s.typeName(), s)
+
+		for _, m := range s.GetMethods() {
+			addSymbol(r.methods, m.typeName(), m)
+		}
+	}
+}
+
+func (r *Registry) addEnums(enums []*EnumDescriptor) {
+	for _, e := range enums {
+		addSymbol(r.enums, e.typeName(), e)
+	}
+}
+
+func (r *Registry) addExtensions(scope string, exts []*ExtensionDescriptor) {
+	for _, ext := range exts {
+		addSymbol(r.extensions, scope+"."+ext.GetName(), ext)
+	}
+
+	// extensions can also be found by their (extendee-based) full name unless that's ambiguous
+	for _, ext := range exts {
+		addSymbol(r.extensions, ext.typeName(), ext)
+	}
+}
+
+func (r *Registry) addMessages(msgs []*Descriptor) {
+	for _, m := range msgs {
+		addSymbol(r.messages, m.typeName(), m)
+		r.addEnums(m.GetEnums())
+		r.addExtensions(m.typeName(), m.GetExtensions())
+		r.addMessages(m.GetMessages())
+	}
+}
+
+func addSymbol[T any](symbols map[string]T, name string, value T) {
+	if _, ok := symbols[name]; !ok {
+		symbols[name] = value
+	}
+}
+
+// linkTypes resolves the type references of all fields, extensions and methods to their descriptors. Since the request
+// includes every dependency, references to types defined in imported files are resolved as well.
+func (r *Registry) linkTypes() {
+	for _, f := range r.filesByName {
+		r.linkExtensions(f.GetExtensions())
+		r.linkMessages(f.GetMessages())
+
+		for _, svc := range f.GetServices() {
+			for _, m := range svc.GetMethods() {
+				m.Input = r.messages[m.GetInputType()]
+				m.Output = r.messages[m.GetOutputType()]
+			}
+		}
+	}
+}
+
+func (r *Registry) linkMessages(msgs []*Descriptor) {
+	for _, m := range msgs {
+		for _, f := range m.GetMessageFields() {
+			f.MessageType = r.messages[f.GetTypeName()]
+			f.EnumType = r.enums[f.GetTypeName()]
+		}
+
+		r.linkExtensions(m.GetExtensions())
+		r.linkMessages(m.GetMessages())
+	}
+}
+
+func (r *Registry) linkExtensions(exts []*ExtensionDescriptor) {
+	for _, ext := range exts {
+		ext.ExtendedMessage = r.messages[ext.GetExtendee()]
+		ext.MessageType = r.messages[ext.GetTypeName()]
+		ext.EnumType = r.enums[ext.GetTypeName()]
+	}
+}
@@ -0,0 +1,68 @@
+package protokit_test
+
+import (
+	"testing"
+
+	"github.com/pseudomuto/protokit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	reg := protokit.NewRegistry(fixtureRequest(t, "booking.proto", "todo.proto"))
+
+	require.Len(t, reg.Files(), 11)
+	require.Equal(t, []string{"booking.proto", "todo.proto"}, fileNames(reg.FilesToGenerate()))
+
+	// dependencies always come first
+	seen := make(map[*protokit.FileDescriptor]bool)
+	for _, f := range reg.Files() {
+		for _, dep := range f.GetDependencies() {
+			require.True(t, seen[dep], "%s listed before its dependency %s", f.GetName(), dep.GetName())
+		}
+		seen[f] = true
+	}
+
+	booking := reg.FindFile("booking.proto")
+	require.Equal(t, reg.FilesToGenerate()[0], booking)
+	require.Equal(t, reg, booking.GetRegistry())
+	require.Nil(t, reg.FindFile("whodis.proto"))
+}
+
+func TestRegistryFind(t *testing.T) {
+	t.Parallel()
+
+	reg := protokit.NewRegistry(fixtureRequest(t, "booking.proto", "todo.proto"))
+	booking := reg.FindFile("booking.proto")
+	todo := reg.FindFile("todo.proto")
+
+	msg := reg.FindMessage(".com.pseudomuto.protokit.v1.Booking")
+	require.Equal(t, booking.GetMessage("Booking"), msg)
+	require.Equal(t, msg, reg.FindMessage("com.pseudomuto.protokit.v1.Booking"))
+	require.Equal(t, todo.GetMessage("CreateListResponse").GetMessage("Status"),
+		reg.FindMessage(".com.pseudomuto.protokit.v1.CreateListResponse.Status"))
+	require.Equal(t, "google/protobuf/timestamp.proto", reg.FindMessage(".google.protobuf.Timestamp").GetFile().GetName())
+	require.Nil(t, reg.FindMessage(".com.pseudomuto.protokit.v1.Whodis"))
+
+	require.Equal(t, todo.GetEnum("ListType"), reg.FindEnum(".com.pseudomuto.protokit.v1.ListType"))
+	require.Equal(t, todo.GetMessage("Item").GetEnum("Status"), reg.FindEnum("com.pseudomuto.protokit.v1.Item.Status"))
+	require.Nil(t, reg.FindEnum(".com.pseudomuto.protokit.v1.Booking"))
+
+	svc := reg.FindService(".com.pseudomuto.protokit.v1.Todo")
+	require.Equal(t, todo.GetService("Todo"), svc)
+	require.Equal(t, svc.GetNamedMethod("AddItem"), reg.FindMethod(".com.pseudomuto.protokit.v1.Todo.AddItem"))
+	require.Nil(t, reg.FindMethod(".com.pseudomuto.protokit.v1.Todo.Whodis"))
+
+	ext := booking.GetExtensions()[0]
+	require.Equal(t, ext, reg.FindExtension(".com.pseudomuto.protokit.v1.country"))
+	require.Equal(t, ext, reg.FindExtension(".com.pseudomuto.protokit.v1.BookingStatus.country"))
+
+	nested := booking.GetMessage("Booking").GetExtensions()[0]
+	require.Equal(t, nested, reg.FindExtension(".com.pseudomuto.protokit.v1.Booking.optional_field_1"))
+
+	// keys in OptionExtensions can be used to find the extension declaration
+	for name := range booking.OptionExtensions {
+		require.Equal(t, "extend.proto", reg.FindExtension(name).GetFile().GetName())
+	}
+}
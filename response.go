@@ -0,0 +1,172 @@
+package protokit
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
+)
+
+// ErrDuplicateOutput indicates that a file with the same name has already been added to a `ResponseBuilder`
+var ErrDuplicateOutput = errors.New("duplicate output file")
+
+// ErrMissingInsertionPoint indicates that `ResponseBuilder.CreateInsertion` was called without an insertion point
+var ErrMissingInsertionPoint = errors.New("missing insertion point")
+
+// A SourceElement is a parsed object that generated code can be annotated with (e.g. a message or a field). All
+// descriptor types apart from `FileDescriptor` implement it.
+type SourceElement interface {
+	GetFile() *FileDescriptor
+	GetSourcePath() []int32
+}
+
+// A ResponseBuilder builds a `CodeGeneratorResponse` from output files written to as `io.Writer`s. For example:
+//
+//	b := protokit.NewResponseBuilder()
+//	for _, file := range protokit.ParseCodeGenRequest(req) {
+//		out, err := b.Create(file.GetName() + ".txt")
+//		if err != nil {
+//			return nil, err
+//		}
+//
+//		for _, msg := range file.GetMessages() {
+//			fmt.Fprint(out, "message ")
+//			out.Annotate(msg, msg.GetName())
+//			fmt.Fprintln(out)
+//		}
+//	}
+//
+//	return b.Response(), nil
+type ResponseBuilder struct {
+	files []*OutputFile
+	names map[string]bool
+}
+
+// NewResponseBuilder returns an empty `ResponseBuilder`
+func NewResponseBuilder() *ResponseBuilder {
+	return &ResponseBuilder{names: make(map[string]bool)}
+}
+
+// Create adds a new output file with the given name. An error wrapping `ErrDuplicateOutput` is returned if the name is
+// already taken.
+func (b *ResponseBuilder) Create(name string) (*OutputFile, error) {
+	if b.names[name] {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateOutput, name)
+	}
+
+	b.names[name] = true
+	return b.add(name, ""), nil
+}
+
+// CreateInsertion adds content to be inserted into the named file at the given insertion point (see
+// `CodeGeneratorResponse.File.insertion_point`). The file is either generated by another plugin or created by this
+// builder. Inserting at the same point more than once is allowed, the contents are inserted in the order they were
+// created. An error wrapping `ErrMissingInsertionPoint` is returned if the insertion point is empty, since protoc would
+// treat the content as a whole file (use `Create` for that).
+func (b *ResponseBuilder) CreateInsertion(name, insertionPoint string) (*OutputFile, error) {
+	if insertionPoint == "" {
+		return nil, fmt.Errorf("%w: %s", ErrMissingInsertionPoint, name)
+	}
+
+	return b.add(name, insertionPoint), nil
+}
+
+func (b *ResponseBuilder) add(name, insertionPoint string) *OutputFile {
+	f := &OutputFile{name: name, insertionPoint: insertionPoint}
+	b.files = append(b.files, f)
+
+	return f
+}
+
+// Response returns the response with all output files. Files are sorted by name so the response doesn't depend on the
+// order they were created in, except that insertions come after the file with the same name (protoc requires this)
+// and keep their relative order.
+func (b *ResponseBuilder) Response() *pluginpb.CodeGeneratorResponse {
+	files := slices.Clone(b.files)
+	slices.SortStableFunc(files, func(x, y *OutputFile) int {
+		if c := cmp.Compare(x.name, y.name); c != 0 {
+			return c
+		}
+
+		switch {
+		case x.insertionPoint == "" && y.insertionPoint != "":
+			return -1
+		case x.insertionPoint != "" && y.insertionPoint == "":
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	resp := new(pluginpb.CodeGeneratorResponse)
+	for _, f := range files {
+		resp.File = append(resp.File, f.proto())
+	}
+
+	return resp
+}
+
+// An OutputFile is a generated file (or content for an insertion point) being written
+type OutputFile struct {
+	name           string
+	insertionPoint string
+	content        bytes.Buffer
+	annotations    []*descriptorpb.GeneratedCodeInfo_Annotation
+}
+
+// GetName returns the name of the file
+func (f *OutputFile) GetName() string { return f.name }
+
+// GetInsertionPoint returns the insertion point the content is for (empty for regular files)
+func (f *OutputFile) GetInsertionPoint() string { return f.insertionPoint }
+
+// Len returns the number of bytes written so far, i.e. the offset of the next write
+func (f *OutputFile) Len() int { return f.content.Len() }
+
+// Write implements `io.Writer`
+func (f *OutputFile) Write(p []byte) (int, error) { return f.content.Write(p) }
+
+// WriteString implements `io.StringWriter`
+func (f *OutputFile) WriteString(s string) (int, error) { return f.content.WriteString(s) }
+
+// Annotate writes text and records that it was generated from elem (see `GeneratedCodeInfo`)
+func (f *OutputFile) Annotate(elem SourceElement, text string) (int, error) {
+	begin := f.Len()
+	n, err := f.WriteString(text)
+	f.AnnotateRange(elem, begin, begin+n)
+
+	return n, err
+}
+
+// AnnotateRange records that the bytes from begin (inclusive) to end (exclusive) were generated from elem. Use `Len`
+// to get the offsets before and after writing the content.
+func (f *OutputFile) AnnotateRange(elem SourceElement, begin, end int) {
+	f.annotations = append(f.annotations, &descriptorpb.GeneratedCodeInfo_Annotation{
+		Path:       elem.GetSourcePath(),
+		SourceFile: proto.String(elem.GetFile().GetName()),
+		Begin:      proto.Int32(int32(begin)),
+		End:        proto.Int32(int32(end)),
+	})
+}
+
+func (f *OutputFile) proto() *pluginpb.CodeGeneratorResponse_File {
+	file := &pluginpb.CodeGeneratorResponse_File{
+		Name:    proto.String(f.name),
+		Content: proto.String(f.content.String()),
+	}
+
+	if f.insertionPoint != "" {
+		file.InsertionPoint = proto.String(f.insertionPoint)
+	}
+
+	if len(f.annotations) > 0 {
+		file.GeneratedCodeInfo = &descriptorpb.GeneratedCodeInfo{Annotation: f.annotations}
+	}
+
+	return file
+}
@@ -0,0 +1,126 @@
+package protokit_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pseudomuto/protokit"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestResponseBuilder(t *testing.T) {
+	t.Parallel()
+
+	b := protokit.NewResponseBuilder()
+
+	// the file is inserted into after being created
+	ins, err := b.CreateInsertion("b.txt", "imports")
+	require.NoError(t, err)
+	_, err = fmt.Fprint(ins, "first")
+	require.NoError(t, err)
+
+	out, err := b.Create("b.txt")
+	require.NoError(t, err)
+	_, err = fmt.Fprint(out, "b contents")
+	require.NoError(t, err)
+
+	ins, err = b.CreateInsertion("b.txt", "imports")
+	require.NoError(t, err)
+	ins.WriteString("second")
+
+	out, err = b.Create("a.txt")
+	require.NoError(t, err)
+	out.WriteString("a contents")
+
+	resp := b.Response()
+	require.Len(t, resp.GetFile(), 4)
+
+	require.Equal(t, "a.txt", resp.GetFile()[0].GetName())
+	require.Equal(t, "a contents", resp.GetFile()[0].GetContent())
+	require.Nil(t, resp.GetFile()[0].InsertionPoint)
+	require.Nil(t, resp.GetFile()[0].GetGeneratedCodeInfo())
+
+	require.Equal(t, "b.txt", resp.GetFile()[1].GetName())
+	require.Equal(t, "b contents", resp.GetFile()[1].GetContent())
+
+	for i, content := range []string{"first", "second"} {
+		f := resp.GetFile()[i+2]
+		require.Equal(t, "b.txt", f.GetName())
+		require.Equal(t, "imports", f.GetInsertionPoint())
+		require.Equal(t, content, f.GetContent())
+	}
+
+	// building again produces the same response
+	require.True(t, proto.Equal(resp, b.Response()))
+}
+
+func TestResponseBuilderDuplicates(t *testing.T) {
+	t.Parallel()
+
+	b := protokit.NewResponseBuilder()
+
+	_, err := b.Create("a.txt")
+	require.NoError(t, err)
+
+	_, err = b.Create("a.txt")
+	require.EqualError(t, err, "duplicate output file: a.txt")
+	require.True(t, errors.Is(err, protokit.ErrDuplicateOutput))
+
+	// an insertion without an insertion point would replace the file
+	_, err = b.CreateInsertion("a.txt", "")
+	require.EqualError(t, err, "missing insertion point: a.txt")
+	require.True(t, errors.Is(err, protokit.ErrMissingInsertionPoint))
+
+	require.Len(t, b.Response().GetFile(), 1)
+}
+
+func TestResponseBuilderAnnotations(t *testing.T) {
+	t.Parallel()
+
+	file := protokit.ParseCodeGenRequest(fixtureRequest(t, "booking.proto"))[0]
+	msg := file.GetMessage("Booking")
+	field := msg.GetMessageField("customer_id")
+
+	b := protokit.NewResponseBuilder()
+	out, err := b.Create("booking.txt")
+	require.NoError(t, err)
+
+	out.WriteString("type ")
+	n, err := out.Annotate(msg, msg.GetName())
+	require.NoError(t, err)
+	require.Equal(t, 7, n)
+
+	out.WriteString(" {\n  ")
+	begin := out.Len()
+	fmt.Fprintf(out, "%s int32", field.GetName())
+	out.AnnotateRange(field, begin, out.Len())
+	out.WriteString("\n}\n")
+
+	f := b.Response().GetFile()[0]
+	require.Equal(t, "type Booking {\n  customer_id int32\n}\n", f.GetContent())
+
+	annotations := f.GetGeneratedCodeInfo().GetAnnotation()
+	require.Len(t, annotations, 2)
+
+	require.Equal(t, []int32{4, 1}, annotations[0].GetPath())
+	require.Equal(t, "booking.proto", annotations[0].GetSourceFile())
+	require.Equal(t, "Booking", f.GetContent()[annotations[0].GetBegin():annotations[0].GetEnd()])
+
+	require.Equal(t, []int32{4, 1, 2, 1}, annotations[1].GetPath())
+	require.Equal(t, "customer_id int32", f.GetContent()[annotations[1].GetBegin():annotations[1].GetEnd()])
+	require.Equal(t, descriptorpb.GeneratedCodeInfo_Annotation_NONE, annotations[1].GetSemantic())
+}
+
+func TestSourcePath(t *testing.T) {
+	t.Parallel()
+
+	file := protokit.ParseCodeGenRequest(fixtureRequest(t, "booking.proto"))[0]
+
+	require.Equal(t, []int32{5, 0, 2, 1}, file.GetEnum("BookingType").GetNamedValue("FUTURE").GetSourcePath())
+	require.Equal(t, []int32{6, 0, 2, 0}, file.GetService("BookingService").GetNamedMethod("BookVehicle").GetSourcePath())
+	require.Equal(t, []int32{4, 1, 6, 0}, file.GetMessage("Booking").GetExtensions()[0].GetSourcePath())
+	require.Equal(t, []int32{7, 0}, file.GetExtensions()[0].GetSourcePath())
+}
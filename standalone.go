@@ -0,0 +1,264 @@
+package protokit
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pseudomuto/protokit/utils"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
+)
+
+// ErrStandaloneUsage is returned when the standalone mode flags are missing or invalid
+var ErrStandaloneUsage = errors.New("invalid usage")
+
+// RunPluginStandalone runs the plugin without protoc. The request is built from a `FileDescriptorSet` (e.g. one
+// generated with `protoc --descriptor_set_out`) or by compiling .proto files (see `utils.CompileProtos`), and the
+// output files are written to disk. This makes it easy to debug a plugin or iterate on its output. The supported flags
+// are:
+//
+//	--descriptor_set_in=PATHS  the descriptor set(s) to load, separated by os.PathListSeparator
+//	--proto_path=DIR           a directory to search for .proto files to compile, can be repeated
+//	--file=NAME                a file to generate, can be repeated (required)
+//	--param=PARAM              a plugin parameter, e.g. k=v, can be repeated
+//	--out=DIR                  the directory to write the output files to (defaults to .)
+//
+// Exactly one of `--descriptor_set_in` and `--proto_path` must be set. E.g.
+//
+//	protoc-gen-example --descriptor_set_in=fixtures/fileset.pb --file=booking.proto --param=k=v --out=gen
+//	protoc-gen-example --proto_path=fixtures --file=booking.proto --out=gen
+//
+// Insertion points are applied to the files generated by the same run. An error is returned if the plugin reports one
+// in the response.
+func RunPluginStandalone(ctx context.Context, p Plugin, args []string) error {
+	opts, err := parseStandaloneArgs(args)
+	if err != nil {
+		return err
+	}
+
+	req, err := opts.request()
+	if err != nil {
+		return err
+	}
+
+	resp, err := runRequest(ctx, p, req)
+	if err != nil {
+		return err
+	}
+
+	if resp.GetError() != "" {
+		return errors.New(resp.GetError())
+	}
+
+	return writeFiles(opts.out, resp.GetFile())
+}
+
+// standaloneFlags are the flags that switch `RunPlugin` to standalone mode. `--param` isn't one of them since it's
+// not enough to run the plugin on its own.
+var standaloneFlags = map[string]bool{"descriptor_set_in": true, "proto_path": true, "file": true, "out": true}
+
+// standaloneArgs returns whether the plugin should run in standalone mode and the arguments to run it with. That's the
+// case when args include one of the standalone mode flags, in any of the forms accepted by the flag package (e.g.
+// `--file=a.proto`, `-file a.proto`). Other arguments are left to the plugin, which is still run by protoc.
+//
+// protoc always pipes the request to stdin, so when stdin is a terminal the plugin was run by hand. Rather than waiting
+// for a request that never comes, it's run in standalone mode without arguments, which prints the usage.
+func standaloneArgs(args []string, stdin *os.File) ([]string, bool) {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+
+		name, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-"), "=")
+		if strings.HasPrefix(arg, "-") && standaloneFlags[name] {
+			return args, true
+		}
+	}
+
+	fi, err := stdin.Stat()
+	return nil, err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+type standaloneOptions struct {
+	descriptorSets string
+	protoPaths     []string
+	files          []string
+	params         []string
+	out            string
+}
+
+func parseStandaloneArgs(args []string) (*standaloneOptions, error) {
+	opts := new(standaloneOptions)
+
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	fs.StringVar(&opts.descriptorSets, "descriptor_set_in", "", "the descriptor set(s) to load")
+	fs.StringVar(&opts.out, "out", ".", "the directory to write the output files to")
+	fs.Func("proto_path", "a directory to search for .proto files to compile (can be repeated)", func(s string) error {
+		opts.protoPaths = append(opts.protoPaths, s)
+		return nil
+	})
+	fs.Func("file", "a file to generate (can be repeated)", func(s string) error {
+		opts.files = append(opts.files, s)
+		return nil
+	})
+	fs.Func("param", "a plugin parameter, e.g. k=v (can be repeated)", func(s string) error {
+		opts.params = append(opts.params, s)
+		return nil
+	})
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	var problem string
+	switch {
+	case fs.NArg() > 0:
+		problem = "unexpected arguments: " + strings.Join(fs.Args(), " ")
+	case opts.descriptorSets == "" && len(opts.protoPaths) == 0:
+		problem = "--descriptor_set_in or --proto_path is required"
+	case opts.descriptorSets != "" && len(opts.protoPaths) > 0:
+		problem = "--descriptor_set_in and --proto_path can't be used together"
+	case len(opts.files) == 0:
+		problem = "at least one --file is required"
+	default:
+		return opts, nil
+	}
+
+	fs.Usage()
+	return nil, fmt.Errorf("%w: %s", ErrStandaloneUsage, problem)
+}
+
+// request loads the descriptor sets (or compiles the files) and builds the request
+func (o *standaloneOptions) request() (*pluginpb.CodeGeneratorRequest, error) {
+	fds, err := o.descriptorSet()
+	if err != nil {
+		return nil, err
+	}
+
+	req := utils.CreateGenRequest(fds, o.files...)
+	if len(o.params) > 0 {
+		req.Parameter = proto.String(strings.Join(o.params, ","))
+	}
+
+	return req, nil
+}
+
+// descriptorSet returns the compiled files or the merged descriptor sets (see `utils.MergeDescriptorSets`)
+func (o *standaloneOptions) descriptorSet() (*descriptorpb.FileDescriptorSet, error) {
+	if len(o.protoPaths) > 0 {
+		return utils.CompileProtos(o.protoPaths, o.files...)
+	}
+
+	var sets []*descriptorpb.FileDescriptorSet
+	for _, path := range filepath.SplitList(o.descriptorSets) {
+		set, err := utils.LoadDescriptorSet(path)
+		if err != nil {
+			return nil, err
+		}
+
+		sets = append(sets, set)
+	}
+
+	fds, err := utils.MergeDescriptorSets(sets...)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, f := range fds.GetFile() {
+		names[f.GetName()] = true
+	}
+
+	for _, name := range o.files {
+		if !names[name] {
+			return nil, fmt.Errorf("%w: %s not found in %s", ErrStandaloneUsage, name, o.descriptorSets)
+		}
+	}
+
+	return fds, nil
+}
+
+// writeFiles writes the output files to dir, after applying insertions to the files they target
+func writeFiles(dir string, files []*pluginpb.CodeGeneratorResponse_File) error {
+	var names []string
+	contents := make(map[string]string)
+
+	for _, f := range files {
+		name := f.GetName()
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("invalid output file name: %q", name)
+		}
+
+		content, ok := contents[name]
+		if f.InsertionPoint == nil {
+			if !ok {
+				names = append(names, name)
+			}
+
+			contents[name] = f.GetContent()
+			continue
+		}
+
+		if !ok {
+			return fmt.Errorf("insertion point %q: %s was not generated", f.GetInsertionPoint(), name)
+		}
+
+		content, err := insert(content, f.GetInsertionPoint(), f.GetContent())
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		contents[name] = content
+	}
+
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			return err
+		}
+
+		//nolint:gosec // generated files are meant to be readable
+		if err := os.WriteFile(path, []byte(contents[name]), 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insert adds text before the line containing `@@protoc_insertion_point(point)`, indenting each line the same as the
+// insertion point line (like protoc does)
+func insert(content, point, text string) (string, error) {
+	i := strings.Index(content, "@@protoc_insertion_point("+point+")")
+	if i < 0 {
+		return "", fmt.Errorf("insertion point %q not found", point)
+	}
+
+	lineStart := strings.LastIndexByte(content[:i], '\n') + 1
+	line := content[lineStart:i]
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+	b := new(strings.Builder)
+	b.WriteString(content[:lineStart])
+
+	for l := range strings.Lines(text) {
+		if l != "\n" {
+			b.WriteString(indent)
+		}
+
+		b.WriteString(l)
+	}
+
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		b.WriteByte('\n')
+	}
+
+	b.WriteString(content[lineStart:])
+	return b.String(), nil
+}
@@ -0,0 +1,224 @@
+package protokit_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/pseudomuto/protokit"
+	"github.com/pseudomuto/protokit/utils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestRunPluginStandalone(t *testing.T) {
+	t.Parallel()
+
+	out := t.TempDir()
+	p := &FuncPlugin{generate: func(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+		require.Equal(t, []string{"booking.proto", "todo.proto"}, req.GetFileToGenerate())
+		require.Equal(t, "mode=fast,verbose", req.GetParameter())
+
+		return &pluginpb.CodeGeneratorResponse{File: []*pluginpb.CodeGeneratorResponse_File{
+			{Name: proto.String("out/files.txt"), Content: proto.String("files:\n  // @@protoc_insertion_point(files)\n")},
+			{Name: proto.String("out/files.txt"), InsertionPoint: proto.String("files"), Content: proto.String("a\n\nb")},
+			{Name: proto.String("out/files.txt"), InsertionPoint: proto.String("files"), Content: proto.String("c\n")},
+			{Name: proto.String("other.txt"), Content: proto.String("other")},
+		}}, nil
+	}}
+
+	err := protokit.RunPluginStandalone(context.Background(), p, []string{
+		"--descriptor_set_in=" + filepath.Join("fixtures", "fileset.pb"),
+		"--file=todo.proto",
+		"--file=booking.proto",
+		"--param=mode=fast",
+		"--param=verbose",
+		"--out=" + out,
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(out, "out", "files.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "files:\n  a\n\n  b\n  c\n  // @@protoc_insertion_point(files)\n", string(data))
+
+	data, err = os.ReadFile(filepath.Join(out, "other.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "other", string(data))
+}
+
+func TestRunPluginStandaloneErrors(t *testing.T) {
+	t.Parallel()
+
+	fileset := "--descriptor_set_in=" + filepath.Join("fixtures", "fileset.pb")
+	respond := func(files ...*pluginpb.CodeGeneratorResponse_File) protokit.Plugin {
+		return &FuncPlugin{generate: func(*pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+			return &pluginpb.CodeGeneratorResponse{File: files}, nil
+		}}
+	}
+
+	tests := []struct {
+		args []string
+		p    protokit.Plugin
+		err  string
+	}{
+		{[]string{"--file=booking.proto"}, respond(), "invalid usage: --descriptor_set_in or --proto_path is required"},
+		{
+			[]string{fileset, "--proto_path=fixtures", "--file=booking.proto"},
+			respond(),
+			"invalid usage: --descriptor_set_in and --proto_path can't be used together",
+		},
+		{[]string{fileset}, respond(), "invalid usage: at least one --file is required"},
+		{[]string{fileset, "--file=booking.proto", "extra"}, respond(), "invalid usage: unexpected arguments: extra"},
+		{
+			[]string{fileset, "--file=nope.proto"},
+			respond(),
+			"invalid usage: nope.proto not found in " + filepath.Join("fixtures", "fileset.pb"),
+		},
+		{
+			[]string{fileset, "--file=booking.proto"},
+			&FuncPlugin{generate: func(*pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+				return nil, protokit.NewGeneratorError(nil, "not today")
+			}},
+			"not today",
+		},
+		{
+			[]string{fileset, "--file=booking.proto"},
+			respond(&pluginpb.CodeGeneratorResponse_File{Name: proto.String("../escape.txt")}),
+			`invalid output file name: "../escape.txt"`,
+		},
+		{
+			[]string{fileset, "--file=booking.proto"},
+			respond(&pluginpb.CodeGeneratorResponse_File{Name: proto.String("a.txt"), InsertionPoint: proto.String("x")}),
+			`insertion point "x": a.txt was not generated`,
+		},
+		{
+			[]string{fileset, "--file=booking.proto"},
+			respond(
+				&pluginpb.CodeGeneratorResponse_File{Name: proto.String("a.txt")},
+				&pluginpb.CodeGeneratorResponse_File{Name: proto.String("a.txt"), InsertionPoint: proto.String("x")},
+			),
+			`a.txt: insertion point "x" not found`,
+		},
+	}
+
+	for _, test := range tests {
+		args := append([]string{"--out=" + t.TempDir()}, test.args...)
+		err := protokit.RunPluginStandalone(context.Background(), test.p, args)
+		require.EqualError(t, err, test.err)
+	}
+
+	err := protokit.RunPluginStandalone(context.Background(), respond(), nil)
+	require.True(t, errors.Is(err, protokit.ErrStandaloneUsage))
+}
+
+func TestRunPluginStandaloneNilResponse(t *testing.T) {
+	t.Parallel()
+
+	out := t.TempDir()
+	p := &FuncPlugin{generate: func(*pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+		return nil, nil
+	}}
+
+	err := protokit.RunPluginStandalone(context.Background(), p, []string{
+		"--descriptor_set_in=" + filepath.Join("fixtures", "fileset.pb"),
+		"--file=booking.proto",
+		"--out=" + out,
+	})
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(out)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestRunPluginStandaloneProtoPath(t *testing.T) {
+	t.Parallel()
+
+	out := t.TempDir()
+	p := protokit.NewFilePlugin(protokit.FileGeneratorFunc(
+		func(_ context.Context, fd *protokit.FileDescriptor) ([]*protokit.File, error) {
+			content := fd.GetMessage("Booking").GetComments().GetLeading()
+			return []*protokit.File{{Name: proto.String(fd.GetName() + ".txt"), Content: proto.String(content)}}, nil
+		},
+	))
+
+	err := protokit.RunPluginStandalone(context.Background(), p, []string{
+		"--proto_path=fixtures",
+		"--file=booking.proto",
+		"--out=" + out,
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(out, "booking.proto.txt"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "Represents the booking of a vehicle.")
+}
+
+func TestRunPluginOtherArgs(t *testing.T) {
+	t.Parallel()
+
+	fds, err := utils.LoadDescriptorSet("fixtures", "fileset.pb")
+	require.NoError(t, err)
+
+	data, err := proto.Marshal(utils.CreateGenRequest(fds, "booking.proto"))
+	require.NoError(t, err)
+
+	// the test binary's own flags are unrelated, so the request is still read from stdin
+	out, _, err := runPluginProcess(t, bytes.NewReader(data))
+	require.NoError(t, err)
+
+	resp := new(pluginpb.CodeGeneratorResponse)
+	require.NoError(t, proto.Unmarshal(out, resp))
+	require.Len(t, resp.GetFile(), 1)
+	require.Equal(t, "myfile.out", resp.GetFile()[0].GetName())
+}
+
+func TestRunPluginInteractive(t *testing.T) {
+	t.Parallel()
+
+	// without stdin, the process gets /dev/null, which is a character device like a terminal
+	out, stderr, err := runPluginProcess(t, nil)
+	require.Error(t, err)
+	require.Equal(t, "invalid usage: --descriptor_set_in or --proto_path is required", string(out))
+	require.Contains(t, string(stderr), "-descriptor_set_in")
+}
+
+// TestPluginProcess isn't a test, it runs protokit.RunPlugin when the test binary is started by runPluginProcess
+func TestPluginProcess(t *testing.T) {
+	t.Parallel()
+
+	if os.Getenv("PROTOKIT_TEST_RUN_PLUGIN") != "1" {
+		t.Skip("only run by runPluginProcess")
+	}
+
+	if err := protokit.RunPlugin(new(OkPlugin)); err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+// runPluginProcess runs protokit.RunPlugin in a copy of the test binary, which has its own (unrelated) flags, and
+// returns what it wrote to stdout and stderr
+func runPluginProcess(t *testing.T, stdin io.Reader) ([]byte, []byte, error) {
+	t.Helper()
+
+	//nolint:gosec // runs the test binary itself
+	cmd := exec.CommandContext(t.Context(), os.Args[0], "-test.run=^TestPluginProcess$", "-test.count=1")
+	cmd.Env = append(os.Environ(), "PROTOKIT_TEST_RUN_PLUGIN=1")
+	cmd.Stdin = stdin
+
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+	return out, stderr.Bytes(), err
+}
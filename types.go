@@ -1,16 +1,22 @@
 package protokit
 
 import (
+	"context"
 	"fmt"
 	"maps"
+	"strconv"
 	"strings"
 
-	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
-	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/descriptorpb"
 )
 
+// field numbers of the key and value fields in map entry messages
+const (
+	mapEntryKeyField   = 1
+	mapEntryValueField = 2
+)
+
 type (
 	common struct {
 		file     *FileDescriptor
@@ -19,6 +25,14 @@ type (
 		FullName string
 
 		OptionExtensions map[string]any
+
+		// Location is where this object is defined and OptionLocations are the positions of its options. See
+		// `GetLocation` and `GetOptionLocation`.
+		Location        *Location
+		OptionLocations map[string]*Location
+
+		// ResolvedFeatures are the effective edition features for this object. See `GetResolvedFeatures`.
+		ResolvedFeatures *descriptorpb.FeatureSet
 	}
 
 	// An ImportedDescriptor describes a type that was imported by a FileDescriptor.
@@ -28,7 +42,9 @@ type (
 
 	// A FileDescriptor describes a single proto file with all of its messages, enums, services, etc.
 	FileDescriptor struct {
-		comments Comments
+		comments  Comments
+		locations Locations
+		registry  *Registry
 		*descriptorpb.FileDescriptorProto
 
 		PackageComments *Comment
@@ -41,7 +57,23 @@ type (
 		Messages   []*Descriptor
 		Services   []*ServiceDescriptor
 
+		// Dependencies are the files directly imported by this file (in import order). PublicDependencies and
+		// WeakDependencies are the subsets imported with `import public` and `import weak` respectively.
+		Dependencies       []*FileDescriptor
+		PublicDependencies []*FileDescriptor
+		WeakDependencies   []*FileDescriptor
+
+		// ImportedBy are the files in the request that directly import this file
+		ImportedBy []*FileDescriptor
+
 		OptionExtensions map[string]any
+
+		// Location spans the whole file and OptionLocations are the positions of the file options
+		Location        *Location
+		OptionLocations map[string]*Location
+
+		// ResolvedFeatures are the effective edition features for this file. See `GetResolvedFeatures`.
+		ResolvedFeatures *descriptorpb.FeatureSet
 	}
 
 	// An EnumDescriptor describe an enum type
@@ -67,6 +99,10 @@ type (
 		*descriptorpb.FieldDescriptorProto
 		Parent   *Descriptor
 		Comments *Comment
+
+		ExtendedMessage *Descriptor
+		MessageType     *Descriptor
+		EnumType        *EnumDescriptor
 	}
 
 	// A Descriptor describes a message
@@ -79,6 +115,7 @@ type (
 		Extensions []*ExtensionDescriptor
 		Fields     []*FieldDescriptor
 		Messages   []*Descriptor
+		Oneofs     []*OneofDescriptor
 	}
 
 	// A FieldDescriptor describes a message field
@@ -87,6 +124,20 @@ type (
 		*descriptorpb.FieldDescriptorProto
 		Comments *Comment
 		Message  *Descriptor
+		Oneof    *OneofDescriptor
+
+		MessageType *Descriptor
+		EnumType    *EnumDescriptor
+	}
+
+	// A OneofDescriptor describes a oneof within a message. Synthetic oneofs, which protoc generates for proto3
+	// `optional` fields, are included as well (see `IsSynthetic`).
+	OneofDescriptor struct {
+		common
+		*descriptorpb.OneofDescriptorProto
+		Comments *Comment
+		Message  *Descriptor
+		Fields   []*FieldDescriptor
 	}
 
 	// A ServiceDescriptor describes a service
@@ -103,6 +154,9 @@ type (
 		*descriptorpb.MethodDescriptorProto
 		Comments *Comment
 		Service  *ServiceDescriptor
+
+		Input  *Descriptor
+		Output *Descriptor
 	}
 )
 
@@ -127,134 +181,49 @@ func (c *common) GetEdition() descriptorpb.Edition { return c.file.GetEdition()
 // IsEditions returns whether or not this object belongs to a file using editions syntax
 func (c *common) IsEditions() bool { return c.file.IsEditions() }
 
-func getOptions(options proto.Message) (m map[string]any) {
-	// In protobuf v2, we need to access extension fields through reflection
-	// and parse unknown fields that contain extension data
-	msg := options.ProtoReflect()
-
-	// First, check for any known extension fields that are set
-	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
-		if fd.IsExtension() {
-			if m == nil {
-				m = make(map[string]any)
-			}
-			m[string(fd.FullName())] = v.Interface()
-		}
-		return true
-	})
-
-	// For custom extensions that might not be registered, we need to parse
-	// the unknown fields. This is more complex in v2 but necessary for
-	// backward compatibility with v1 behavior.
-	unknownFields := msg.GetUnknown()
-	if len(unknownFields) > 0 {
-		// Parse known extension field numbers for this message type
-		extensions := getKnownExtensions(options)
-		for fieldNum, extInfo := range extensions {
-			if value := parseExtensionFromUnknown(unknownFields, fieldNum, extInfo.wireType); value != nil {
-				if m == nil {
-					m = make(map[string]any)
-				}
-				m[extInfo.name] = value
-			}
-		}
-	}
-
-	return m
-}
-
-// ExtensionInfo holds information about known extensions
-type ExtensionInfo struct {
-	name     string
-	wireType int
-}
-
-// getKnownExtensions returns a map of field numbers to extension info for common protobuf options
-func getKnownExtensions(options proto.Message) map[int32]ExtensionInfo {
-	extensions := make(map[int32]ExtensionInfo)
-
-	// Define the known extensions based on the test proto files
-	// These correspond to the extensions defined in extend.proto
-	switch options.(type) {
-	case *descriptorpb.FileOptions:
-		extensions[20000] = ExtensionInfo{"com.pseudomuto.protokit.v1.extend_file", int(protowire.VarintType)} // varint
-	case *descriptorpb.ServiceOptions:
-		extensions[20000] = ExtensionInfo{"com.pseudomuto.protokit.v1.extend_service", int(protowire.VarintType)} // varint
-	case *descriptorpb.MethodOptions:
-		extensions[20000] = ExtensionInfo{"com.pseudomuto.protokit.v1.extend_method", int(protowire.VarintType)} // varint
-	case *descriptorpb.MessageOptions:
-		extensions[20000] = ExtensionInfo{"com.pseudomuto.protokit.v1.extend_message", int(protowire.VarintType)} // varint
-	case *descriptorpb.FieldOptions:
-		extensions[20000] = ExtensionInfo{"com.pseudomuto.protokit.v1.extend_field", int(protowire.VarintType)} // varint
-	case *descriptorpb.EnumOptions:
-		extensions[20000] = ExtensionInfo{"com.pseudomuto.protokit.v1.extend_enum", int(protowire.VarintType)} // varint
-	case *descriptorpb.EnumValueOptions:
-		extensions[20000] = ExtensionInfo{"com.pseudomuto.protokit.v1.extend_enum_value", int(protowire.VarintType)} // varint
-	}
-
-	return extensions
-}
-
-// parseExtensionFromUnknown attempts to parse an extension value from unknown fields
-func parseExtensionFromUnknown(unknownFields protoreflect.RawFields, fieldNum int32, wireType int) any {
-	// This is a simplified parser for boolean extensions (wire type 0 - varint)
-	// In a full implementation, you'd need to handle all wire types
-	if wireType != int(protowire.VarintType) {
-		return nil // Only handle varint for now
-	}
-
-	// Parse the unknown fields looking for our field number
-	for len(unknownFields) > 0 {
-		fieldNumParsed, wireTypeParsed, fieldData := parseField(unknownFields)
-		if fieldNumParsed == fieldNum && wireTypeParsed == int(protowire.VarintType) {
-			// Parse varint (boolean in our case)
-			if len(fieldData) > 0 && fieldData[0] == 1 {
-				val := true
-				return &val
-			} else if len(fieldData) > 0 && fieldData[0] == 0 {
-				val := false
-				return &val
-			}
-		}
-		// Skip this field and continue
-		unknownFields = unknownFields[len(unknownFields)-len(fieldData):]
-		if len(unknownFields) == 0 {
-			break
+// GetResolvedFeatures returns the effective edition features for this object. These start with the defaults for the
+// file's edition and are overridden by the `features` option of each enclosing element (file, message, oneof, etc.)
+// and finally the object itself. For proto2 and proto3 files, the features implied by the syntax (e.g. `required`
+// fields or the `packed` option) are used.
+//
+// The returned value may be shared with other descriptors and must not be modified.
+func (c *common) GetResolvedFeatures() *descriptorpb.FeatureSet { return c.ResolvedFeatures }
+
+// GetLocation returns where this object is defined. If the request doesn't include source info, the location is
+// invalid (see `Location.IsValid`), but still has the file name.
+func (c *common) GetLocation() *Location { return c.Location }
+
+// GetSourcePath returns the path of this object within its file, as used by `SourceCodeInfo` and `GeneratedCodeInfo`
+// (e.g. `[4, 0, 2, 1]` for the second field of the first message)
+func (c *common) GetSourcePath() []int32 {
+	parts := strings.Split(c.path, ".")
+	path := make([]int32, 0, len(parts))
+
+	for _, p := range parts {
+		if n, err := strconv.ParseInt(p, 10, 32); err == nil {
+			path = append(path, int32(n))
 		}
 	}
 
-	return nil
+	return path
 }
 
-// parseField parses a single field from raw protobuf data
-// Returns field number, wire type, and remaining data
-func parseField(data protoreflect.RawFields) (int32, int, protoreflect.RawFields) {
-	if len(data) == 0 {
-		return 0, 0, nil
+// GetOptionLocation returns the position of the specified option. Standard options are named after their field (e.g.
+// `deprecated`) and custom options by their full name, i.e. the key in `OptionExtensions`. If the option isn't set,
+// the location is invalid.
+func (c *common) GetOptionLocation(name string) *Location {
+	if loc, ok := c.OptionLocations[name]; ok {
+		return loc
 	}
 
-	// Parse the tag (field number and wire type)
-	fieldNum, wireType, n := protowire.ConsumeTag([]byte(data))
-	if n <= 0 {
-		return 0, 0, nil
-	}
-	data = data[n:]
-
-	// For varint (wire type 0), parse the value
-	if wireType == protowire.VarintType {
-		_, valueLen := protowire.ConsumeVarint([]byte(data))
-		if valueLen <= 0 {
-			return int32(fieldNum), int(wireType), nil
-		}
-		return int32(fieldNum), int(wireType), data[:valueLen]
-	}
-
-	// For other wire types, we'd need more complex parsing (YAGNI).
-	return int32(fieldNum), int(wireType), data
+	return &Location{File: c.file.GetName()}
 }
 
-func (c *common) setOptions(options proto.Message) {
-	if opts := getOptions(options); len(opts) > 0 {
+func (c *common) setOptions(ctx context.Context, options proto.Message) {
+	msg := resolveOptions(ctx, options)
+	c.OptionLocations = optionLocations(c.file.locations, c.path, msg)
+
+	if opts := getOptions(msg); len(opts) > 0 {
 		if c.OptionExtensions == nil {
 			c.OptionExtensions = opts
 			return
@@ -334,28 +303,19 @@ func (f *FileDescriptor) GetEditionComments() *Comment { return f.EditionComment
 // HasExplicitFieldPresence returns whether this file defaults to explicit field presence
 // In editions 2023+, field presence is explicit by default (like proto2)
 // In proto3, field presence is implicit by default
+//
+// Messages and fields can override the file's default in editions. Use `FieldDescriptor.HasPresence` to check a
+// specific field.
 func (f *FileDescriptor) HasExplicitFieldPresence() bool {
-	if f.IsEditions() {
-		// Check custom field presence setting in editions
-		if options := f.GetOptions(); options != nil {
-			if features := options.GetFeatures(); features != nil {
-				switch features.GetFieldPresence() {
-				case descriptorpb.FeatureSet_IMPLICIT:
-					return false
-				case descriptorpb.FeatureSet_EXPLICIT, descriptorpb.FeatureSet_LEGACY_REQUIRED:
-					return true
-				case descriptorpb.FeatureSet_FIELD_PRESENCE_UNKNOWN:
-					// Fall through to default behavior
-				}
-			}
-		}
-		// Editions 2023+ default to explicit field presence
-		return true
-	}
-	// proto2 has explicit field presence, proto3 has implicit
-	return f.GetSyntax() == "proto2"
+	return f.GetResolvedFeatures().GetFieldPresence() != descriptorpb.FeatureSet_IMPLICIT
 }
 
+// GetResolvedFeatures returns the effective edition features for this file. These are the defaults for the file's
+// edition overridden by the file's `features` option.
+//
+// The returned value may be shared with other descriptors and must not be modified.
+func (f *FileDescriptor) GetResolvedFeatures() *descriptorpb.FeatureSet { return f.ResolvedFeatures }
+
 // GetSyntaxType returns a more detailed syntax classification
 func (f *FileDescriptor) GetSyntaxType() string {
 	if f.IsEditions() {
@@ -376,6 +336,27 @@ func (f *FileDescriptor) GetImports() []*ImportedDescriptor { return f.Imports }
 // GetMessages returns the top-level messages defined in this file
 func (f *FileDescriptor) GetMessages() []*Descriptor { return f.Messages }
 
+// GetRegistry returns the registry containing every file from the request this file was parsed from
+func (f *FileDescriptor) GetRegistry() *Registry { return f.registry }
+
+// GetDependencies returns the files directly imported by this file
+func (f *FileDescriptor) GetDependencies() []*FileDescriptor { return f.Dependencies }
+
+// GetPublicDependencies returns the files imported by this file with `import public`
+func (f *FileDescriptor) GetPublicDependencies() []*FileDescriptor { return f.PublicDependencies }
+
+// GetWeakDependencies returns the files imported by this file with `import weak`
+func (f *FileDescriptor) GetWeakDependencies() []*FileDescriptor { return f.WeakDependencies }
+
+// GetImportedBy returns the files in the request that directly import this file
+func (f *FileDescriptor) GetImportedBy() []*FileDescriptor { return f.ImportedBy }
+
+// GetTransitiveDependencies returns every file this file depends on, directly or indirectly. Files are ordered such
+// that each file comes after all of its own dependencies.
+func (f *FileDescriptor) GetTransitiveDependencies() []*FileDescriptor {
+	return SortFiles(f.Dependencies)
+}
+
 // GetServices returns the services defined in this file
 func (f *FileDescriptor) GetServices() []*ServiceDescriptor { return f.Services }
 
@@ -412,8 +393,30 @@ func (f *FileDescriptor) GetService(name string) *ServiceDescriptor {
 	return nil
 }
 
-func (f *FileDescriptor) setOptions(options proto.Message) {
-	if opts := getOptions(options); len(opts) > 0 {
+// GetLocation returns the location of the whole file. If the request doesn't include source info, the location is
+// invalid (see `Location.IsValid`), but still has the file name.
+func (f *FileDescriptor) GetLocation() *Location { return f.Location }
+
+// GetOptionLocation returns the position of the specified file option. See `common.GetOptionLocation` for how options
+// are named.
+func (f *FileDescriptor) GetOptionLocation(name string) *Location {
+	if loc, ok := f.OptionLocations[name]; ok {
+		return loc
+	}
+
+	return &Location{File: f.GetName()}
+}
+
+// location returns the location for the given source path. Unknown locations only have the file name.
+func (f *FileDescriptor) location(path string) *Location {
+	return f.locations.Get(path)
+}
+
+func (f *FileDescriptor) setOptions(ctx context.Context, options proto.Message) {
+	msg := resolveOptions(ctx, options)
+	f.OptionLocations = optionLocations(f.locations, "", msg)
+
+	if opts := getOptions(msg); len(opts) > 0 {
 		if f.OptionExtensions == nil {
 			f.OptionExtensions = opts
 			return
@@ -445,6 +448,16 @@ func (e *EnumDescriptor) GetNamedValue(name string) *EnumValueDescriptor {
 	return nil
 }
 
+// GetReservedRangeLocation returns the position of the reserved range at the specified index (see `GetReservedRange`)
+func (e *EnumDescriptor) GetReservedRangeLocation(index int) *Location {
+	return e.childLocation(enumReservedRangePath, index)
+}
+
+// GetReservedNameLocation returns the position of the reserved name at the specified index (see `GetReservedName`)
+func (e *EnumDescriptor) GetReservedNameLocation(index int) *Location {
+	return e.childLocation(enumReservedNamePath, index)
+}
+
 // EnumValueDescriptor methods
 
 // GetComments returns a description of the value
@@ -461,6 +474,16 @@ func (e *ExtensionDescriptor) GetComments() *Comment { return e.Comments }
 // GetParent returns the descriptor that defined this extension (if any)
 func (e *ExtensionDescriptor) GetParent() *Descriptor { return e.Parent }
 
+// GetExtendedMessage returns the message being extended (i.e. the extendee). Returns `nil` if the extendee isn't part
+// of the parsed request
+func (e *ExtensionDescriptor) GetExtendedMessage() *Descriptor { return e.ExtendedMessage }
+
+// GetMessageType returns the message type of this extension (returns `nil` if it's not a message or group extension)
+func (e *ExtensionDescriptor) GetMessageType() *Descriptor { return e.MessageType }
+
+// GetEnumType returns the enum type of this extension (returns `nil` if it's not an enum extension)
+func (e *ExtensionDescriptor) GetEnumType() *EnumDescriptor { return e.EnumType }
+
 // Descriptor methods
 
 // GetComments returns a description of the message
@@ -478,6 +501,22 @@ func (m *Descriptor) GetExtensions() []*ExtensionDescriptor { return m.Extension
 // GetMessages returns the nested messages within the message
 func (m *Descriptor) GetMessages() []*Descriptor { return m.Messages }
 
+// GetMessagesWithoutMapEntries returns the nested messages within the message, leaving out the synthetic `*Entry` types
+// that protoc generates for map fields
+func (m *Descriptor) GetMessagesWithoutMapEntries() []*Descriptor {
+	msgs := make([]*Descriptor, 0, len(m.Messages))
+	for _, msg := range m.Messages {
+		if !msg.IsMapEntry() {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	return msgs
+}
+
+// IsMapEntry returns whether or not this message is a synthetic entry type generated for a map field
+func (m *Descriptor) IsMapEntry() bool { return m.GetOptions().GetMapEntry() }
+
 // GetMessageFields returns the message fields
 func (m *Descriptor) GetMessageFields() []*FieldDescriptor { return m.Fields }
 
@@ -507,6 +546,20 @@ func (m *Descriptor) GetMessage(name string) *Descriptor {
 	return nil
 }
 
+// GetOneofs returns the oneofs declared in the message (including synthetic ones)
+func (m *Descriptor) GetOneofs() []*OneofDescriptor { return m.Oneofs }
+
+// GetOneof returns the oneof with the specified name (returns `nil` if not found)
+func (m *Descriptor) GetOneof(name string) *OneofDescriptor {
+	for _, o := range m.GetOneofs() {
+		if o.GetName() == name || o.GetLongName() == name {
+			return o
+		}
+	}
+
+	return nil
+}
+
 // GetMessageField returns the field with the specified name (returns `nil` if not found)
 func (m *Descriptor) GetMessageField(name string) *FieldDescriptor {
 	for _, f := range m.GetMessageFields() {
@@ -518,6 +571,16 @@ func (m *Descriptor) GetMessageField(name string) *FieldDescriptor {
 	return nil
 }
 
+// GetReservedRangeLocation returns the position of the reserved range at the specified index (see `GetReservedRange`)
+func (m *Descriptor) GetReservedRangeLocation(index int) *Location {
+	return m.childLocation(messageReservedRangePath, index)
+}
+
+// GetReservedNameLocation returns the position of the reserved name at the specified index (see `GetReservedName`)
+func (m *Descriptor) GetReservedNameLocation(index int) *Location {
+	return m.childLocation(messageReservedNamePath, index)
+}
+
 // FieldDescriptor methods
 
 // GetComments returns a description of the field
@@ -526,6 +589,78 @@ func (mf *FieldDescriptor) GetComments() *Comment { return mf.Comments }
 // GetMessage returns the descriptor that defines this field
 func (mf *FieldDescriptor) GetMessage() *Descriptor { return mf.Message }
 
+// GetMessageType returns the message type of this field (returns `nil` if it's not a message or group field)
+func (mf *FieldDescriptor) GetMessageType() *Descriptor { return mf.MessageType }
+
+// GetEnumType returns the enum type of this field (returns `nil` if it's not an enum field)
+func (mf *FieldDescriptor) GetEnumType() *EnumDescriptor { return mf.EnumType }
+
+// GetOneof returns the oneof this field belongs to (returns `nil` if it's not part of a oneof)
+func (mf *FieldDescriptor) GetOneof() *OneofDescriptor { return mf.Oneof }
+
+// HasPresence returns whether or not this field tracks presence, i.e. whether it's possible to tell if the field was
+// set. This is the case for singular message fields, fields that are part of a oneof (including proto3 `optional`
+// fields), and any other singular field with explicit presence as per its resolved features.
+func (mf *FieldDescriptor) HasPresence() bool {
+	switch {
+	case mf.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED:
+		return false
+	case mf.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE,
+		mf.GetType() == descriptorpb.FieldDescriptorProto_TYPE_GROUP,
+		mf.Oneof != nil:
+		return true
+	default:
+		return mf.GetResolvedFeatures().GetFieldPresence() != descriptorpb.FeatureSet_IMPLICIT
+	}
+}
+
+// IsMap returns whether or not this is a map field
+func (mf *FieldDescriptor) IsMap() bool {
+	return mf.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED &&
+		mf.MessageType != nil &&
+		mf.MessageType.IsMapEntry()
+}
+
+// GetMapKey returns the key field of the map entry (returns `nil` if this isn't a map field)
+func (mf *FieldDescriptor) GetMapKey() *FieldDescriptor { return mf.mapEntryField(mapEntryKeyField) }
+
+// GetMapValue returns the value field of the map entry (returns `nil` if this isn't a map field). Use the value field's
+// `GetMessageType` and `GetEnumType` to get at the resolved value type.
+func (mf *FieldDescriptor) GetMapValue() *FieldDescriptor {
+	return mf.mapEntryField(mapEntryValueField)
+}
+
+func (mf *FieldDescriptor) mapEntryField(number int32) *FieldDescriptor {
+	if !mf.IsMap() {
+		return nil
+	}
+
+	for _, f := range mf.MessageType.GetMessageFields() {
+		if f.GetNumber() == number {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// OneofDescriptor methods
+
+// GetComments returns a description of the oneof
+func (o *OneofDescriptor) GetComments() *Comment { return o.Comments }
+
+// GetMessage returns the descriptor that defines this oneof
+func (o *OneofDescriptor) GetMessage() *Descriptor { return o.Message }
+
+// GetFields returns the fields that are members of this oneof
+func (o *OneofDescriptor) GetFields() []*FieldDescriptor { return o.Fields }
+
+// IsSynthetic returns whether or not this oneof was generated by protoc for a proto3 `optional` field. Synthetic oneofs
+// always contain a single field and aren't part of the message's API.
+func (o *OneofDescriptor) IsSynthetic() bool {
+	return len(o.Fields) == 1 && o.Fields[0].GetProto3Optional()
+}
+
 // ServiceDescriptor methods
 
 // GetComments returns a description of the service
@@ -553,6 +688,20 @@ func (m *MethodDescriptor) GetComments() *Comment { return m.Comments }
 // GetService returns the service descriptor that defines this method
 func (m *MethodDescriptor) GetService() *ServiceDescriptor { return m.Service }
 
+// GetInput returns the request message for this method. Returns `nil` if the type isn't part of the parsed request
+func (m *MethodDescriptor) GetInput() *Descriptor { return m.Input }
+
+// GetOutput returns the response message for this method. Returns `nil` if the type isn't part of the parsed request
+func (m *MethodDescriptor) GetOutput() *Descriptor { return m.Output }
+
+// childLocation returns the location of the element at index in the repeated field with the given tag number
+func (c *common) childLocation(tag, index int) *Location {
+	return c.file.location(fmt.Sprintf("%s.%d.%d", c.path, tag, index))
+}
+
+// typeName returns the fully-qualified name with a leading dot, as used in type references (e.g. `.pkg.Message`)
+func (c *common) typeName() string { return "." + strings.TrimPrefix(c.FullName, ".") }
+
 // newCommon creates a new common struct with the given parameters.
 func newCommon(f *FileDescriptor, path, longName string) common {
 	fn := longName
@@ -565,5 +714,6 @@ func newCommon(f *FileDescriptor, path, longName string) common {
 		path:     path,
 		LongName: longName,
 		FullName: fn,
+		Location: f.location(path),
 	}
 }
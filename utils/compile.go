@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/bufbuild/protocompile/linker"
+	"github.com/bufbuild/protocompile/reporter"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// CompileProtos compiles the named .proto files into a `FileDescriptorSet` without running protoc. The files and their
+// imports are looked up in the import paths (or the current directory when there are none), while the standard imports
+// like `google/protobuf/descriptor.proto` are built in. The result is equivalent to running:
+//
+//	protoc --descriptor_set_out=fileset.pb --include_imports --include_source_info -I importPaths... files...
+//
+// i.e. it includes all transitive dependencies (ordered so that files come after their imports) and source code info.
+// Syntax and link errors are returned together (see `errors.Join`), sorted by position and formatted like
+// `booking.proto:12:3: message`.
+func CompileProtos(importPaths []string, files ...string) (*descriptorpb.FileDescriptorSet, error) {
+	return compileProtos(&protocompile.SourceResolver{ImportPaths: importPaths}, files)
+}
+
+// CompileProtoSources is like `CompileProtos`, but takes the source code of the files (and of any imports that aren't
+// standard imports) keyed by their path. This is mostly useful for tests. E.g.
+//
+//	fds, err := utils.CompileProtoSources(map[string]string{
+//		"greeter.proto": `syntax = "proto3"; message Hello { string name = 1; }`,
+//	}, "greeter.proto")
+func CompileProtoSources(sources map[string]string, files ...string) (*descriptorpb.FileDescriptorSet, error) {
+	return compileProtos(&protocompile.SourceResolver{Accessor: protocompile.SourceAccessorFromMap(sources)}, files)
+}
+
+func compileProtos(resolver protocompile.Resolver, files []string) (*descriptorpb.FileDescriptorSet, error) {
+	var errs []reporter.ErrorWithPos
+
+	compiler := protocompile.Compiler{
+		Resolver:       protocompile.WithStandardImports(resolver),
+		SourceInfoMode: protocompile.SourceInfoStandard,
+		Reporter: reporter.NewReporter(func(err reporter.ErrorWithPos) error {
+			// keep going to report as many problems as possible
+			errs = append(errs, err)
+			return nil
+		}, nil),
+	}
+
+	compiled, err := compiler.Compile(context.Background(), files...)
+	if len(errs) > 0 {
+		return nil, joinErrors(errs)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	set := new(descriptorpb.FileDescriptorSet)
+	seen := make(map[string]bool)
+
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+
+		seen[fd.Path()] = true
+
+		imports := fd.Imports()
+		for i := range imports.Len() {
+			add(imports.Get(i).FileDescriptor)
+		}
+
+		set.File = append(set.File, fileDescriptorProto(fd))
+	}
+
+	for _, fd := range compiled {
+		add(fd)
+	}
+
+	return normalize(set)
+}
+
+// fileDescriptorProto returns the descriptor proto for a compiled file. Files compiled from source keep the descriptor
+// produced by the compiler, which includes the source code info.
+func fileDescriptorProto(fd protoreflect.FileDescriptor) *descriptorpb.FileDescriptorProto {
+	if res, ok := fd.(linker.Result); ok {
+		return res.FileDescriptorProto()
+	}
+
+	return protodesc.ToFileDescriptorProto(fd)
+}
+
+// normalize round trips the set through the binary format so that it's the same as a set loaded with
+// `LoadDescriptorSet`. The compiler resolves custom options into extension fields, whereas protoc's output is parsed
+// with custom options as unknown fields (unless they're linked into the binary).
+func normalize(set *descriptorpb.FileDescriptorSet) (*descriptorpb.FileDescriptorSet, error) {
+	data, err := proto.Marshal(set)
+	if err != nil {
+		return nil, err
+	}
+
+	normalized := new(descriptorpb.FileDescriptorSet)
+	if err := proto.Unmarshal(data, normalized); err != nil {
+		return nil, err
+	}
+
+	return normalized, nil
+}
+
+// joinErrors sorts the errors by position since files are compiled concurrently
+func joinErrors(errs []reporter.ErrorWithPos) error {
+	slices.SortStableFunc(errs, func(a, b reporter.ErrorWithPos) int {
+		x, y := a.GetPosition(), b.GetPosition()
+		return cmp.Or(cmp.Compare(x.Filename, y.Filename), cmp.Compare(x.Line, y.Line), cmp.Compare(x.Col, y.Col))
+	})
+
+	joined := make([]error, len(errs))
+	for i, err := range errs {
+		joined[i] = err
+	}
+
+	return errors.Join(joined...)
+}
@@ -0,0 +1,123 @@
+package utils_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"testing"
+
+	"github.com/pseudomuto/protokit/utils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestCompileProtos(t *testing.T) {
+	t.Parallel()
+
+	expected, err := utils.LoadDescriptorSet("..", "fixtures", "fileset.pb")
+	require.NoError(t, err)
+
+	// edition 2024 isn't supported by the compiler yet
+	files := []string{"booking.proto", "todo.proto", "extend.proto", "edition2023.proto", "edition2023_implicit.proto"}
+	fds, err := utils.CompileProtos([]string{"../fixtures"}, files...)
+	require.NoError(t, err)
+
+	names := make([]string, 0)
+	for _, fd := range fds.GetFile() {
+		names = append(names, fd.GetName())
+
+		want := utils.FindDescriptor(expected, fd.GetName())
+		if want == nil || want.GetName() != fd.GetName() {
+			continue
+		}
+
+		// the result matches protoc's output, apart from the order of the locations and a few spans (e.g. protoc points
+		// at the value of default options rather than the option)
+		require.Equal(t, comments(want), comments(fd), fd.GetName())
+
+		fd, want = proto.CloneOf(fd), proto.CloneOf(want)
+		fd.SourceCodeInfo, want.SourceCodeInfo = nil, nil
+		require.True(t, proto.Equal(want, fd), fd.GetName())
+	}
+
+	// imports come before the files that import them
+	require.Equal(t, []string{
+		"google/protobuf/descriptor.proto",
+		"extend.proto",
+		"booking.proto",
+		"google/protobuf/any.proto",
+		"google/protobuf/timestamp.proto",
+		"todo_import.proto",
+		"todo.proto",
+		"edition2023.proto",
+		"edition2023_implicit.proto",
+	}, names)
+}
+
+// comments returns the comments for each location keyed by path
+func comments(fd *descriptorpb.FileDescriptorProto) map[string][]string {
+	m := make(map[string][]string)
+	for _, loc := range fd.GetSourceCodeInfo().GetLocation() {
+		key := fmt.Sprint(loc.GetPath())
+		if key == "[14]" {
+			// protoc records the edition directive under the syntax path
+			key = "[12]"
+		}
+
+		m[key] = append(m[key], loc.GetLeadingComments()+"|"+loc.GetTrailingComments())
+	}
+
+	return m
+}
+
+func TestCompileProtoSources(t *testing.T) {
+	t.Parallel()
+
+	fds, err := utils.CompileProtoSources(map[string]string{
+		"greeter.proto": `
+syntax = "proto3";
+
+package greeter;
+
+import "google/protobuf/timestamp.proto";
+import "common.proto";
+
+// Says hello
+message Hello {
+  string name = 1;
+  google.protobuf.Timestamp at = 2;
+  common.Lang lang = 3;
+}
+`,
+		"common.proto": `syntax = "proto3"; package common; enum Lang { LANG_UNSPECIFIED = 0; }`,
+	}, "greeter.proto")
+	require.NoError(t, err)
+	require.Len(t, fds.GetFile(), 3)
+
+	fd := utils.FindDescriptor(fds, "greeter.proto")
+	require.Equal(t, "Hello", fd.GetMessageType()[0].GetName())
+	require.Equal(t, []string{"google/protobuf/timestamp.proto", "common.proto"}, fd.GetDependency())
+	require.Equal(t, map[string][]string{"[4 0]": {" Says hello\n|"}}, filterComments(comments(fd)))
+}
+
+func TestCompileProtosErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := utils.CompileProtoSources(map[string]string{
+		"a.proto": "syntax = \"proto3\";\nmessage A { Missing m = 1; }\n",
+		"b.proto": "syntax = \"proto3\";\nmessage B { string s = 1; int32 s = 2; }\n",
+	}, "b.proto", "a.proto")
+	require.EqualError(t, err, "a.proto:2:13: field A.m: unknown type Missing\n"+
+		`b.proto:2:33: symbol "B.s" already defined at b.proto:2:20`)
+
+	_, err = utils.CompileProtos([]string{"../fixtures"}, "missing.proto")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
+// filterComments removes locations without comments
+func filterComments(m map[string][]string) map[string][]string {
+	maps.DeleteFunc(m, func(_ string, v []string) bool { return v[0] == "|" })
+	return m
+}
@@ -0,0 +1,238 @@
+package utils
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pseudomuto/protokit/internal/extensions"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ErrFileConflict indicates that descriptor sets being merged contain different versions of the same file
+var ErrFileConflict = errors.New("conflicting file")
+
+// fileSetFileTag is the tag of `file` in the binary format of `FileDescriptorSet` (field 1, length-delimited)
+const fileSetFileTag = 0x0a
+
+// bufExtensionField is the field number of `buf_extension` in buf's `ImageFile`, which is otherwise wire compatible
+// with `FileDescriptorProto`
+const bufExtensionField = 8042
+
+// ReadDescriptorSet reads a `FileDescriptorSet` from r. The encoding is detected automatically, and can be any of:
+//
+//   - the binary format, as produced by `protoc --descriptor_set_out` or `buf build -o image.binpb`
+//   - JSON, as produced by `buf build -o image.json`
+//   - the text format, as produced by `buf build -o image.txtpb`
+//   - any of the above compressed with gzip
+//
+// The format is guessed from the content, and the binary format is tried next when the guess turns out to be wrong (a
+// binary set can look like JSON or text). Buf images are read as regular descriptor sets, i.e. buf's image file
+// metadata is dropped.
+func ReadDescriptorSet(r io.Reader) (*descriptorpb.FileDescriptorSet, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if isGzip(data) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		defer func() { _ = zr.Close() }()
+		return ReadDescriptorSet(zr)
+	}
+
+	set, err := unmarshalDescriptorSet(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range set.GetFile() {
+		dropUnknownField(f, bufExtensionField)
+	}
+
+	return set, nil
+}
+
+// LoadDescriptorSetFS is like `LoadDescriptorSet`, but reads the named file from fsys
+func LoadDescriptorSetFS(fsys fs.FS, name string) (*descriptorpb.FileDescriptorSet, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = f.Close() }()
+	return ReadDescriptorSet(f)
+}
+
+// MergeDescriptorSets merges the files from the given sets into a single set, keeping the order they appear in. A file
+// that appears in more than one set is only included once. If the copies differ, an error wrapping `ErrFileConflict`
+// is returned for each such file (combined with `errors.Join`). Source code info is ignored when comparing files, since
+// sets are often built with and without `--include_source_info`. The first copy with source code info is kept.
+func MergeDescriptorSets(sets ...*descriptorpb.FileDescriptorSet) (*descriptorpb.FileDescriptorSet, error) {
+	merged := new(descriptorpb.FileDescriptorSet)
+	index := make(map[string]int) // file name => index in merged
+
+	var errs []error
+	for _, set := range sets {
+		for _, f := range set.GetFile() {
+			i, ok := index[f.GetName()]
+			if !ok {
+				index[f.GetName()] = len(merged.File)
+				merged.File = append(merged.File, f)
+				continue
+			}
+
+			prev := merged.File[i]
+			if !equalIgnoringSourceInfo(prev, f) {
+				err := fmt.Errorf("%w: %s has different contents in different sets", ErrFileConflict, f.GetName())
+				errs = append(errs, err)
+				continue
+			}
+
+			if prev.SourceCodeInfo == nil && f.SourceCodeInfo != nil {
+				merged.File[i] = f
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return merged, nil
+}
+
+// equalIgnoringSourceInfo returns whether the files are the same apart from their source code info, which differs
+// whenever comments or formatting change
+func equalIgnoringSourceInfo(a, b *descriptorpb.FileDescriptorProto) bool {
+	if a.SourceCodeInfo != nil || b.SourceCodeInfo != nil {
+		a, b = proto.CloneOf(a), proto.CloneOf(b)
+		a.SourceCodeInfo, b.SourceCodeInfo = nil, nil
+	}
+
+	return proto.Equal(a, b)
+}
+
+// unmarshalDescriptorSet parses the set in each of the formats it could be in, most likely first. The error for the
+// most likely format is returned if none of them work.
+func unmarshalDescriptorSet(data []byte) (*descriptorpb.FileDescriptorSet, error) {
+	var firstErr error
+	for _, unmarshal := range descriptorSetFormats(data) {
+		set := new(descriptorpb.FileDescriptorSet)
+		err := unmarshal(data, set)
+		if err == nil {
+			return set, nil
+		}
+
+		firstErr = cmp.Or(firstErr, err)
+	}
+
+	return nil, firstErr
+}
+
+// descriptorSetFormats returns the functions for parsing the formats the data could be in, most likely first. A
+// binary set starts with the tag of `file` (0x0a), which is also a newline, so the binary format comes first in that
+// case and last otherwise. JSON and text are recognized by their content, which a binary set can happen to match
+// (e.g. when the length of the first file is 0x7b, i.e. `{`).
+func descriptorSetFormats(data []byte) []func([]byte, *descriptorpb.FileDescriptorSet) error {
+	binary := func(data []byte, set *descriptorpb.FileDescriptorSet) error { return proto.Unmarshal(data, set) }
+
+	var formats []func([]byte, *descriptorpb.FileDescriptorSet) error
+	binaryFirst := len(data) > 0 && data[0] == fileSetFileTag
+	if binaryFirst {
+		formats = append(formats, binary)
+	}
+
+	switch {
+	case bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")):
+		formats = append(formats, func(data []byte, set *descriptorpb.FileDescriptorSet) error {
+			return extensions.Unmarshal(data, set, unmarshalJSON, (*descriptorpb.FileDescriptorSet).GetFile)
+		})
+	case isText(data):
+		formats = append(formats, func(data []byte, set *descriptorpb.FileDescriptorSet) error {
+			return extensions.Unmarshal(data, set, unmarshalText, (*descriptorpb.FileDescriptorSet).GetFile)
+		})
+	}
+
+	if !binaryFirst {
+		formats = append(formats, binary)
+	}
+
+	return formats
+}
+
+func isGzip(data []byte) bool {
+	return len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+// isText returns whether the data looks like the text format. The binary format usually includes control characters or
+// invalid UTF-8 (tags and lengths), while the text format is printable apart from whitespace.
+func isText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+
+	for _, r := range string(data) {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// unmarshalJSON parses JSON. Since buf images include metadata that isn't part of `FileDescriptorProto`, unknown
+// fields are ignored.
+func unmarshalJSON(data []byte, m proto.Message, res extensions.TypeResolver) error {
+	return protojson.UnmarshalOptions{Resolver: res, DiscardUnknown: true}.Unmarshal(data, m)
+}
+
+// unmarshalText parses the text format, ignoring unknown fields like `unmarshalJSON`
+func unmarshalText(data []byte, m proto.Message, res extensions.TypeResolver) error {
+	return prototext.UnmarshalOptions{Resolver: res, DiscardUnknown: true}.Unmarshal(data, m)
+}
+
+// dropUnknownField removes all occurrences of the given field from the message's unknown fields
+func dropUnknownField(m proto.Message, num protowire.Number) {
+	msg := m.ProtoReflect()
+
+	unknown := msg.GetUnknown()
+	if len(unknown) == 0 {
+		return
+	}
+
+	kept := make([]byte, 0, len(unknown))
+	for len(unknown) > 0 {
+		n, typ, tagLen := protowire.ConsumeTag(unknown)
+		if tagLen < 0 {
+			return
+		}
+
+		valueLen := protowire.ConsumeFieldValue(n, typ, unknown[tagLen:])
+		if valueLen < 0 {
+			return
+		}
+
+		if n != num {
+			kept = append(kept, unknown[:tagLen+valueLen]...)
+		}
+
+		unknown = unknown[tagLen+valueLen:]
+	}
+
+	msg.SetUnknown(kept)
+}
@@ -0,0 +1,217 @@
+package utils_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pseudomuto/protokit/utils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestReadDescriptorSet(t *testing.T) {
+	t.Parallel()
+
+	expected, err := utils.LoadDescriptorSet("..", "fixtures", "fileset.pb")
+	require.NoError(t, err)
+
+	binary, err := proto.Marshal(expected)
+	require.NoError(t, err)
+
+	// custom options are only encoded as JSON and text if they're resolved
+	resolved := resolveOptions(t, expected)
+	json, err := protojson.Marshal(resolved)
+	require.NoError(t, err)
+	require.Contains(t, string(json), "[com.pseudomuto.protokit.v1.extend_file]")
+
+	text, err := prototext.Marshal(resolved)
+	require.NoError(t, err)
+
+	// buf images add metadata to each file
+	image := new(descriptorpb.FileDescriptorSet)
+	for _, f := range expected.GetFile() {
+		f = proto.CloneOf(f)
+		ext := protowire.AppendTag(nil, 8042, protowire.BytesType)
+		ext = protowire.AppendBytes(ext, protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), 1))
+		f.ProtoReflect().SetUnknown(append(f.ProtoReflect().GetUnknown(), ext...))
+		image.File = append(image.File, f)
+	}
+
+	imageBinary, err := proto.Marshal(image)
+	require.NoError(t, err)
+
+	imageJSON := strings.Replace(string(json), `"name":`, `"bufExtension":{"isImport":true}, "name":`, 1)
+
+	tests := map[string][]byte{
+		"binary":      binary,
+		"json":        json,
+		"text":        text,
+		"gzip binary": gzipped(t, binary),
+		"gzip json":   gzipped(t, json),
+		"image":       imageBinary,
+		"image json":  []byte(imageJSON),
+	}
+
+	for name, data := range tests {
+		set, err := utils.ReadDescriptorSet(bytes.NewReader(data))
+		require.NoError(t, err, name)
+		require.True(t, proto.Equal(expected, set), name)
+	}
+
+	_, err = utils.ReadDescriptorSet(strings.NewReader("file { name: 'unterminated' "))
+	require.Error(t, err)
+}
+
+func TestReadDescriptorSetAmbiguous(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]*descriptorpb.FileDescriptorSet{
+		// the length of the first file (123) is encoded as `{`
+		"binary like json": {File: []*descriptorpb.FileDescriptorProto{{Name: proto.String(strings.Repeat("a", 121))}}},
+		// every byte is printable, i.e. 0a 09 0a 07 a.proto
+		"binary like text": {File: []*descriptorpb.FileDescriptorProto{{Name: proto.String("a.proto")}}},
+	}
+
+	for name, expected := range tests {
+		data, err := proto.Marshal(expected)
+		require.NoError(t, err, name)
+
+		set, err := utils.ReadDescriptorSet(bytes.NewReader(data))
+		require.NoError(t, err, name)
+		require.True(t, proto.Equal(expected, set), name)
+	}
+
+	// text can start with a newline as well
+	set, err := utils.ReadDescriptorSet(strings.NewReader("\nfile { name: 'a.proto' }\n"))
+	require.NoError(t, err)
+	require.Equal(t, "a.proto", set.GetFile()[0].GetName())
+
+	set, err = utils.ReadDescriptorSet(bytes.NewReader(nil))
+	require.NoError(t, err)
+	require.Empty(t, set.GetFile())
+}
+
+func TestLoadDescriptorSetFS(t *testing.T) {
+	t.Parallel()
+
+	expected, err := utils.LoadDescriptorSet("..", "fixtures", "fileset.pb")
+	require.NoError(t, err)
+
+	text, err := prototext.Marshal(expected)
+	require.NoError(t, err)
+
+	fsys := fstest.MapFS{"sets/fileset.txtpb": &fstest.MapFile{Data: text}}
+
+	set, err := utils.LoadDescriptorSetFS(fsys, "sets/fileset.txtpb")
+	require.NoError(t, err)
+	require.Len(t, set.GetFile(), len(expected.GetFile()))
+
+	_, err = utils.LoadDescriptorSetFS(fsys, "missing.pb")
+	require.Error(t, err)
+}
+
+func TestMergeDescriptorSets(t *testing.T) {
+	t.Parallel()
+
+	file := func(name, pkg string, withSourceInfo bool) *descriptorpb.FileDescriptorProto {
+		f := &descriptorpb.FileDescriptorProto{Name: proto.String(name), Package: proto.String(pkg)}
+		if withSourceInfo {
+			f.SourceCodeInfo = &descriptorpb.SourceCodeInfo{
+				Location: []*descriptorpb.SourceCodeInfo_Location{{Span: []int32{0, 0, 1}}},
+			}
+		}
+
+		return f
+	}
+
+	a := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{
+		file("common.proto", "common", false),
+		file("a.proto", "a", true),
+	}}
+
+	b := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{
+		file("b.proto", "b", true),
+		file("common.proto", "common", true),
+	}}
+
+	merged, err := utils.MergeDescriptorSets(a, b)
+	require.NoError(t, err)
+
+	names := make([]string, 0)
+	for _, f := range merged.GetFile() {
+		names = append(names, f.GetName())
+	}
+
+	require.Equal(t, []string{"common.proto", "a.proto", "b.proto"}, names)
+	require.NotNil(t, merged.GetFile()[0].GetSourceCodeInfo())
+
+	// copies that only differ in their source code info aren't conflicts, and the first one is kept
+	moved := file("a.proto", "a", true)
+	moved.SourceCodeInfo.Location[0].Span = []int32{2, 0, 1}
+	moved.SourceCodeInfo.Location[0].LeadingComments = proto.String(" docs")
+
+	d := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{moved}}
+	merged, err = utils.MergeDescriptorSets(a, d)
+	require.NoError(t, err)
+	require.Equal(t, []int32{0, 0, 1}, merged.GetFile()[1].GetSourceCodeInfo().GetLocation()[0].GetSpan())
+
+	c := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{
+		file("a.proto", "other", true),
+		file("b.proto", "other", false),
+	}}
+
+	_, err = utils.MergeDescriptorSets(a, b, c)
+	require.True(t, errors.Is(err, utils.ErrFileConflict))
+	require.EqualError(t, err, "conflicting file: a.proto has different contents in different sets\n"+
+		"conflicting file: b.proto has different contents in different sets")
+}
+
+// resolveOptions returns a copy of the set where custom options are extension fields rather than unknown fields
+func resolveOptions(t *testing.T, set *descriptorpb.FileDescriptorSet) *descriptorpb.FileDescriptorSet {
+	t.Helper()
+
+	files, err := protodesc.NewFiles(set)
+	require.NoError(t, err)
+
+	types := new(protoregistry.Types)
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		for i := range fd.Extensions().Len() {
+			require.NoError(t, types.RegisterExtension(dynamicpb.NewExtensionType(fd.Extensions().Get(i))))
+		}
+
+		return true
+	})
+
+	data, err := proto.Marshal(set)
+	require.NoError(t, err)
+
+	resolved := new(descriptorpb.FileDescriptorSet)
+	require.NoError(t, proto.UnmarshalOptions{Resolver: types}.Unmarshal(data, resolved))
+
+	return resolved
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
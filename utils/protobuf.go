@@ -6,7 +6,6 @@ import (
 	"path/filepath"
 	"slices"
 
-	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/descriptorpb"
 	pluginpb "google.golang.org/protobuf/types/pluginpb"
 )
@@ -44,23 +43,20 @@ OUTERLOOP:
 }
 
 // LoadDescriptorSet loads a `FileDescriptorSet` from a file on disk. Such a file can be generated using the
-// `--descriptor_set_out` flag with `protoc`.
+// `--descriptor_set_out` flag with `protoc`. Buf images and compressed, JSON and text-format sets are supported too
+// (see `ReadDescriptorSet`).
 //
 // Example:
 //
 //	protoc --descriptor_set_out=fileset.pb --include_imports --include_source_info ./booking.proto ./todo.proto
 func LoadDescriptorSet(pathSegments ...string) (*descriptorpb.FileDescriptorSet, error) {
-	f, err := os.ReadFile(filepath.Join(pathSegments...))
+	f, err := os.Open(filepath.Join(pathSegments...))
 	if err != nil {
 		return nil, err
 	}
 
-	set := new(descriptorpb.FileDescriptorSet)
-	if err = proto.Unmarshal(f, set); err != nil {
-		return nil, err
-	}
-
-	return set, nil
+	defer func() { _ = f.Close() }()
+	return ReadDescriptorSet(f)
 }
 
 // FindDescriptor finds the named descriptor in the given set. Only base names are searched. The first match is
@@ -0,0 +1,528 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ErrRootNotFound indicates that a root passed to `PruneDescriptorSet` isn't in the set
+var ErrRootNotFound = errors.New("root not found")
+
+// Field numbers of `FileDescriptorProto` and `ServiceDescriptorProto` used in source code info paths
+const (
+	fileDependencyPath       = 3
+	fileMessagePath          = 4
+	fileEnumPath             = 5
+	fileServicePath          = 6
+	fileExtensionPath        = 7
+	filePublicDependencyPath = 10
+	fileWeakDependencyPath   = 11
+	serviceMethodPath        = 2
+)
+
+// PruneOptions configures `PruneDescriptorSet`
+type PruneOptions struct {
+	// StripUnreferenced removes the messages, enums, services, methods and extensions that aren't referenced from the
+	// roots, rather than keeping the files as they are
+	StripUnreferenced bool
+}
+
+// PruneDescriptorSet returns a set with only the parts of set needed by the roots. A root is either a file name (e.g.
+// `booking.proto`) or the full name of a message, enum, service or method (e.g.
+// `com.pseudomuto.protokit.v1.BookingService.BookVehicle`). An error wrapping `ErrRootNotFound` is returned for each
+// root that isn't in the set.
+//
+// By default, the result includes the files that declare the roots and all of their transitive imports, unchanged.
+//
+// With `StripUnreferenced`, only the top-level messages, enums and extensions that are (transitively) referenced by
+// the roots are kept, e.g. as field types, method inputs and outputs, or custom options. Nested types are kept along
+// with the top-level message that declares them. Services are kept with the methods that are roots (or all of them when
+// the service or its file is a root). Files that end up empty are removed, and imports of removed files are dropped.
+// Source code info paths are rewritten to match, so comments and locations are still correct.
+//
+// Files are returned in the same order as in set, so files still come after their imports.
+func PruneDescriptorSet(
+	set *descriptorpb.FileDescriptorSet,
+	roots []string,
+	opts PruneOptions,
+) (*descriptorpb.FileDescriptorSet, error) {
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newPruner(files)
+
+	var errs []error
+	for _, root := range roots {
+		if err := p.addRoot(root); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if !opts.StripUnreferenced {
+		return p.fileClosure(set), nil
+	}
+
+	return p.strip(set), nil
+}
+
+type extensionKey struct {
+	extendee protoreflect.FullName
+	number   protoreflect.FieldNumber
+}
+
+type pruner struct {
+	files *protoregistry.Files
+	// extensions declared in the set, used to resolve custom options
+	extensions map[extensionKey]protoreflect.ExtensionDescriptor
+
+	// kept top-level declarations and services, and kept methods
+	kept    map[protoreflect.FullName]bool
+	methods map[protoreflect.FullName]bool
+	// files with at least one kept declaration
+	keptFiles map[string]bool
+}
+
+func newPruner(files *protoregistry.Files) *pruner {
+	p := &pruner{
+		files:      files,
+		extensions: make(map[extensionKey]protoreflect.ExtensionDescriptor),
+		kept:       make(map[protoreflect.FullName]bool),
+		methods:    make(map[protoreflect.FullName]bool),
+		keptFiles:  make(map[string]bool),
+	}
+
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		p.indexExtensions(fd.Extensions(), fd.Messages())
+		return true
+	})
+
+	return p
+}
+
+func (p *pruner) indexExtensions(exts protoreflect.ExtensionDescriptors, msgs protoreflect.MessageDescriptors) {
+	for i := range exts.Len() {
+		ext := exts.Get(i)
+		p.extensions[extensionKey{ext.ContainingMessage().FullName(), ext.Number()}] = ext
+	}
+
+	for i := range msgs.Len() {
+		p.indexExtensions(msgs.Get(i).Extensions(), msgs.Get(i).Messages())
+	}
+}
+
+func (p *pruner) addRoot(root string) error {
+	if fd, err := p.files.FindFileByPath(root); err == nil {
+		p.keepFile(fd)
+
+		return nil
+	}
+
+	d, err := p.files.FindDescriptorByName(protoreflect.FullName(root))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrRootNotFound, root)
+	}
+
+	p.keep(d)
+	return nil
+}
+
+// fileClosure returns the files declaring the roots and their transitive imports
+func (p *pruner) fileClosure(set *descriptorpb.FileDescriptorSet) *descriptorpb.FileDescriptorSet {
+	closure := make(map[string]bool)
+
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if closure[fd.Path()] {
+			return
+		}
+
+		closure[fd.Path()] = true
+
+		imports := fd.Imports()
+		for i := range imports.Len() {
+			add(imports.Get(i).FileDescriptor)
+		}
+	}
+
+	for path := range p.keptFiles {
+		fd, _ := p.files.FindFileByPath(path)
+		add(fd)
+	}
+
+	pruned := new(descriptorpb.FileDescriptorSet)
+	for _, f := range set.GetFile() {
+		if closure[f.GetName()] {
+			pruned.File = append(pruned.File, f)
+		}
+	}
+
+	return pruned
+}
+
+// keepFile keeps all declarations in the file
+func (p *pruner) keepFile(fd protoreflect.FileDescriptor) {
+	p.markFile(fd)
+
+	for i := range fd.Messages().Len() {
+		p.keep(fd.Messages().Get(i))
+	}
+
+	for i := range fd.Enums().Len() {
+		p.keep(fd.Enums().Get(i))
+	}
+
+	for i := range fd.Services().Len() {
+		p.keep(fd.Services().Get(i))
+	}
+
+	for i := range fd.Extensions().Len() {
+		p.keep(fd.Extensions().Get(i))
+	}
+}
+
+// markFile records that the file has kept declarations and keeps the custom options set on the file
+func (p *pruner) markFile(fd protoreflect.FileDescriptor) {
+	if p.keptFiles[fd.Path()] {
+		return
+	}
+
+	p.keptFiles[fd.Path()] = true
+	p.keepOptions(fd.Options())
+}
+
+// keep keeps the descriptor and everything it references. Nested declarations keep their top-level message.
+func (p *pruner) keep(d protoreflect.Descriptor) {
+	switch d := d.(type) {
+	case protoreflect.ServiceDescriptor:
+		p.keepService(d)
+		for i := range d.Methods().Len() {
+			p.keepMethod(d.Methods().Get(i))
+		}
+	case protoreflect.MethodDescriptor:
+		p.keepMethod(d)
+	case protoreflect.FileDescriptor:
+		p.keepFile(d)
+	default:
+		for _, ok := d.Parent().(protoreflect.FileDescriptor); !ok; _, ok = d.Parent().(protoreflect.FileDescriptor) {
+			d = d.Parent()
+		}
+
+		if p.kept[d.FullName()] {
+			return
+		}
+
+		p.kept[d.FullName()] = true
+		p.markFile(d.ParentFile())
+		p.visit(d)
+	}
+}
+
+func (p *pruner) keepService(svc protoreflect.ServiceDescriptor) {
+	if p.kept[svc.FullName()] {
+		return
+	}
+
+	p.kept[svc.FullName()] = true
+	p.markFile(svc.ParentFile())
+	p.keepOptions(svc.Options())
+}
+
+func (p *pruner) keepMethod(method protoreflect.MethodDescriptor) {
+	if p.methods[method.FullName()] {
+		return
+	}
+
+	p.methods[method.FullName()] = true
+	p.keepService(method.Parent().(protoreflect.ServiceDescriptor))
+	p.keepOptions(method.Options())
+	p.keep(method.Input())
+	p.keep(method.Output())
+}
+
+// visit keeps everything referenced by a top-level (or nested) declaration
+func (p *pruner) visit(d protoreflect.Descriptor) {
+	p.keepOptions(d.Options())
+
+	switch d := d.(type) {
+	case protoreflect.MessageDescriptor:
+		for i := range d.Fields().Len() {
+			p.visit(d.Fields().Get(i))
+		}
+
+		for i := range d.Oneofs().Len() {
+			p.keepOptions(d.Oneofs().Get(i).Options())
+		}
+
+		for i := range d.Messages().Len() {
+			p.visit(d.Messages().Get(i))
+		}
+
+		for i := range d.Enums().Len() {
+			p.visit(d.Enums().Get(i))
+		}
+
+		for i := range d.Extensions().Len() {
+			p.visit(d.Extensions().Get(i))
+		}
+	case protoreflect.EnumDescriptor:
+		for i := range d.Values().Len() {
+			p.keepOptions(d.Values().Get(i).Options())
+		}
+	case protoreflect.FieldDescriptor:
+		if d.IsExtension() {
+			p.keep(d.ContainingMessage())
+		}
+
+		if d.Message() != nil {
+			p.keep(d.Message())
+		}
+
+		if d.Enum() != nil {
+			p.keep(d.Enum())
+		}
+	}
+}
+
+// keepOptions keeps the extensions for the custom options set in the options message. Options that aren't linked into
+// the binary are unknown fields, which are resolved using the extensions declared in the set.
+func (p *pruner) keepOptions(options proto.Message) {
+	msg := options.ProtoReflect()
+	if !msg.IsValid() {
+		return
+	}
+
+	msg.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		if fd.IsExtension() {
+			if ext, ok := p.extensions[extensionKey{msg.Descriptor().FullName(), fd.Number()}]; ok {
+				p.keep(ext)
+			}
+		}
+
+		return true
+	})
+
+	for unknown := msg.GetUnknown(); len(unknown) > 0; {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return
+		}
+
+		m := protowire.ConsumeFieldValue(num, typ, unknown[n:])
+		if m < 0 {
+			return
+		}
+
+		if ext, ok := p.extensions[extensionKey{msg.Descriptor().FullName(), num}]; ok {
+			p.keep(ext)
+		}
+
+		unknown = unknown[n+m:]
+	}
+}
+
+// strip returns the kept files with only the kept declarations
+func (p *pruner) strip(set *descriptorpb.FileDescriptorSet) *descriptorpb.FileDescriptorSet {
+	p.keepReexportingFiles()
+
+	pruned := new(descriptorpb.FileDescriptorSet)
+	for _, f := range set.GetFile() {
+		if p.keptFiles[f.GetName()] {
+			fd, _ := p.files.FindFileByPath(f.GetName())
+			pruned.File = append(pruned.File, p.stripFile(f, fd))
+		}
+	}
+
+	return pruned
+}
+
+// keepReexportingFiles keeps the files that publicly import kept files, since the files importing them may rely on
+// the public import to reference the kept declarations
+func (p *pruner) keepReexportingFiles() {
+	for changed := true; changed; {
+		changed = false
+
+		p.files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+			if !p.keptFiles[fd.Path()] {
+				return true
+			}
+
+			imports := fd.Imports()
+			for i := range imports.Len() {
+				imp := imports.Get(i).FileDescriptor
+				if !p.keptFiles[imp.Path()] && p.reexportsKeptFile(imp) {
+					p.keptFiles[imp.Path()] = true
+					changed = true
+				}
+			}
+
+			return true
+		})
+	}
+}
+
+func (p *pruner) reexportsKeptFile(fd protoreflect.FileDescriptor) bool {
+	imports := fd.Imports()
+	for i := range imports.Len() {
+		imp := imports.Get(i)
+		if imp.IsPublic && (p.keptFiles[imp.Path()] || p.reexportsKeptFile(imp.FileDescriptor)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *pruner) stripFile(
+	f *descriptorpb.FileDescriptorProto,
+	fd protoreflect.FileDescriptor,
+) *descriptorpb.FileDescriptorProto {
+	out := proto.CloneOf(f)
+	indexes := make(map[int32][]int)
+
+	indexes[fileDependencyPath] = keptIndexes(len(f.GetDependency()), func(i int) bool {
+		return p.keptFiles[f.GetDependency()[i]]
+	})
+	out.Dependency = filter(f.GetDependency(), indexes[fileDependencyPath])
+	out.PublicDependency, indexes[filePublicDependencyPath] = remapDependencies(f.GetPublicDependency(),
+		indexes[fileDependencyPath])
+	out.WeakDependency, indexes[fileWeakDependencyPath] = remapDependencies(f.GetWeakDependency(),
+		indexes[fileDependencyPath])
+
+	indexes[fileMessagePath] = keptIndexes(fd.Messages().Len(), func(i int) bool {
+		return p.kept[fd.Messages().Get(i).FullName()]
+	})
+	out.MessageType = filter(f.GetMessageType(), indexes[fileMessagePath])
+
+	indexes[fileEnumPath] = keptIndexes(fd.Enums().Len(), func(i int) bool {
+		return p.kept[fd.Enums().Get(i).FullName()]
+	})
+	out.EnumType = filter(f.GetEnumType(), indexes[fileEnumPath])
+
+	indexes[fileExtensionPath] = keptIndexes(fd.Extensions().Len(), func(i int) bool {
+		return p.kept[fd.Extensions().Get(i).FullName()]
+	})
+	out.Extension = filter(f.GetExtension(), indexes[fileExtensionPath])
+
+	indexes[fileServicePath] = keptIndexes(fd.Services().Len(), func(i int) bool {
+		return p.kept[fd.Services().Get(i).FullName()]
+	})
+	out.Service = filter(out.GetService(), indexes[fileServicePath])
+
+	// methods are indexed by the original service index
+	methods := make(map[int][]int)
+	for i, svc := range f.GetService() {
+		if indexes[fileServicePath][i] < 0 {
+			continue
+		}
+
+		sd := fd.Services().Get(i)
+		methods[i] = keptIndexes(sd.Methods().Len(), func(j int) bool { return p.methods[sd.Methods().Get(j).FullName()] })
+		out.Service[indexes[fileServicePath][i]] = proto.CloneOf(svc)
+		out.Service[indexes[fileServicePath][i]].Method = filter(svc.GetMethod(), methods[i])
+	}
+
+	if f.SourceCodeInfo != nil {
+		out.SourceCodeInfo = rewriteSourceCodeInfo(f.GetSourceCodeInfo(), indexes, methods)
+	}
+
+	return out
+}
+
+// keptIndexes returns the new index of each of the n elements, or -1 for the ones that aren't kept
+func keptIndexes(n int, keep func(i int) bool) []int {
+	indexes := make([]int, n)
+
+	next := 0
+	for i := range n {
+		indexes[i] = -1
+		if keep(i) {
+			indexes[i] = next
+			next++
+		}
+	}
+
+	return indexes
+}
+
+func filter[T any](s []T, indexes []int) []T {
+	var kept []T
+	for i, v := range s {
+		if indexes[i] >= 0 {
+			kept = append(kept, v)
+		}
+	}
+
+	return kept
+}
+
+// remapDependencies rewrites the indexes of public or weak dependencies, which refer to the dependency list. It also
+// returns the new index of each entry.
+func remapDependencies(deps []int32, dependencies []int) ([]int32, []int) {
+	indexes := keptIndexes(len(deps), func(i int) bool { return dependencies[deps[i]] >= 0 })
+
+	var remapped []int32
+	for _, dep := range deps {
+		if i := dependencies[dep]; i >= 0 {
+			remapped = append(remapped, int32(i))
+		}
+	}
+
+	return remapped, indexes
+}
+
+// rewriteSourceCodeInfo drops the locations of removed elements and rewrites the paths of the others. indexes maps the
+// original index of each top-level message, enum, service and extension to its new one (keyed by the field number of
+// the list), and methods maps the original method indexes of each service (keyed by the original service index).
+func rewriteSourceCodeInfo(
+	info *descriptorpb.SourceCodeInfo,
+	indexes map[int32][]int,
+	methods map[int][]int,
+) *descriptorpb.SourceCodeInfo {
+	out := new(descriptorpb.SourceCodeInfo)
+
+	for _, loc := range info.GetLocation() {
+		path := loc.GetPath()
+		if len(path) < 2 || indexes[path[0]] == nil {
+			out.Location = append(out.Location, loc)
+			continue
+		}
+
+		// the original index of the top-level element, whichever list it's in
+		index := int(path[1])
+		i := indexes[path[0]][index]
+		if i < 0 {
+			continue
+		}
+
+		path = append([]int32(nil), path...)
+		path[1] = int32(i)
+
+		if path[0] == fileServicePath && len(path) >= 4 && path[2] == serviceMethodPath {
+			j := methods[index][path[3]]
+			if j < 0 {
+				continue
+			}
+
+			path[3] = int32(j)
+		}
+
+		loc = proto.CloneOf(loc)
+		loc.Path = path
+		out.Location = append(out.Location, loc)
+	}
+
+	return out
+}
@@ -0,0 +1,182 @@
+package utils_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/pseudomuto/protokit/utils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestPruneDescriptorSet(t *testing.T) {
+	t.Parallel()
+
+	set, err := utils.LoadDescriptorSet("..", "fixtures", "fileset.pb")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		roots []string
+		files []string
+	}{
+		"file": {
+			roots: []string{"booking.proto"},
+			files: []string{"google/protobuf/descriptor.proto", "extend.proto", "booking.proto"},
+		},
+		"message": {
+			roots: []string{"com.pseudomuto.protokit.v1.List"},
+			files: []string{
+				"google/protobuf/descriptor.proto",
+				"extend.proto",
+				"google/protobuf/any.proto",
+				"google/protobuf/timestamp.proto",
+				"todo_import.proto",
+				"todo.proto",
+			},
+		},
+		"multiple": {
+			roots: []string{"todo_import.proto", "com.pseudomuto.protokit.v1.BookingService.BookVehicle"},
+			files: []string{"google/protobuf/descriptor.proto", "extend.proto", "booking.proto", "todo_import.proto"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			pruned, err := utils.PruneDescriptorSet(set, test.roots, utils.PruneOptions{})
+			require.NoError(t, err)
+			require.Equal(t, test.files, fileNames(pruned))
+
+			// files are kept as they are
+			for _, f := range pruned.GetFile() {
+				require.True(t, slices.Contains(set.GetFile(), f))
+			}
+		})
+	}
+}
+
+func TestPruneDescriptorSetStripUnreferenced(t *testing.T) {
+	t.Parallel()
+
+	set, err := utils.LoadDescriptorSet("..", "fixtures", "fileset.pb")
+	require.NoError(t, err)
+
+	pruned, err := utils.PruneDescriptorSet(
+		set,
+		[]string{"com.pseudomuto.protokit.v1.BookingService.BookVehicle"},
+		utils.PruneOptions{StripUnreferenced: true},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"google/protobuf/descriptor.proto", "extend.proto", "booking.proto"}, fileNames(pruned))
+
+	files, err := protodesc.NewFiles(pruned)
+	require.NoError(t, err)
+
+	fd, err := files.FindFileByPath("booking.proto")
+	require.NoError(t, err)
+	require.Equal(t, []string{"extend.proto"}, importPaths(fd))
+
+	// BookingType and the file-level extension aren't referenced
+	require.Equal(t, 2, fd.Messages().Len())
+	require.Equal(t, 0, fd.Enums().Len())
+	require.Equal(t, 0, fd.Extensions().Len())
+	require.Equal(t, 1, fd.Services().Len())
+
+	// source code info still matches the declarations
+	booking := fd.Messages().ByName("Booking")
+	require.NotNil(t, booking)
+	require.Equal(t, 1, booking.Index())
+	require.Contains(t, fd.SourceLocations().ByDescriptor(booking).LeadingComments, "Represents the booking of a vehicle.")
+
+	method := fd.Services().Get(0).Methods().ByName("BookVehicle")
+	require.Contains(t, fd.SourceLocations().ByDescriptor(method).LeadingComments, "Used to book a vehicle.")
+
+	// only the custom options that are used are kept
+	ext, err := files.FindFileByPath("extend.proto")
+	require.NoError(t, err)
+	require.Nil(t, ext.Extensions().ByName("extend_enum"))
+	require.Nil(t, ext.Extensions().ByName("extend_enum_value"))
+	require.NotNil(t, ext.Extensions().ByName("extend_method"))
+	require.NotNil(t, ext.Extensions().ByName("extend_field"))
+}
+
+func TestPruneDescriptorSetStripMethods(t *testing.T) {
+	t.Parallel()
+
+	set, err := utils.CompileProtoSources(map[string]string{
+		"a.proto": `syntax = "proto3";
+			import "b.proto";
+			service S {
+				// One
+				rpc One(B) returns (B);
+				// Two
+				rpc Two(Unused) returns (Unused);
+			}
+			message Unused {}`,
+		"b.proto": `syntax = "proto3"; import public "c.proto"; import "d.proto";`,
+		"c.proto": `syntax = "proto3"; message B { C c = 1; } message C {}`,
+		"d.proto": `syntax = "proto3"; message D {}`,
+	}, "a.proto")
+	require.NoError(t, err)
+
+	pruned, err := utils.PruneDescriptorSet(set, []string{"S.One"}, utils.PruneOptions{StripUnreferenced: true})
+	require.NoError(t, err)
+
+	// b.proto has no declarations, but is still needed for its public import
+	require.Equal(t, []string{"c.proto", "b.proto", "a.proto"}, fileNames(pruned))
+
+	files, err := protodesc.NewFiles(pruned)
+	require.NoError(t, err)
+
+	fd, err := files.FindFileByPath("b.proto")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c.proto"}, importPaths(fd))
+	require.True(t, fd.Imports().Get(0).IsPublic)
+
+	fd, err = files.FindFileByPath("a.proto")
+	require.NoError(t, err)
+	require.Equal(t, 0, fd.Messages().Len())
+
+	methods := fd.Services().Get(0).Methods()
+	require.Equal(t, 1, methods.Len())
+	require.Equal(t, " One\n", fd.SourceLocations().ByDescriptor(methods.Get(0)).LeadingComments)
+
+	_, err = files.FindDescriptorByName("C")
+	require.NoError(t, err)
+	_, err = files.FindDescriptorByName("D")
+	require.True(t, errors.Is(err, protoregistry.NotFound))
+}
+
+func TestPruneDescriptorSetRootNotFound(t *testing.T) {
+	t.Parallel()
+
+	set, err := utils.LoadDescriptorSet("..", "fixtures", "fileset.pb")
+	require.NoError(t, err)
+
+	_, err = utils.PruneDescriptorSet(set, []string{"missing.proto", "booking.proto", "Missing"}, utils.PruneOptions{})
+	require.True(t, errors.Is(err, utils.ErrRootNotFound))
+	require.EqualError(t, err, "root not found: missing.proto\nroot not found: Missing")
+}
+
+func fileNames(set *descriptorpb.FileDescriptorSet) []string {
+	names := make([]string, len(set.GetFile()))
+	for i, f := range set.GetFile() {
+		names[i] = f.GetName()
+	}
+
+	return names
+}
+
+func importPaths(fd protoreflect.FileDescriptor) []string {
+	paths := make([]string, fd.Imports().Len())
+	for i := range fd.Imports().Len() {
+		paths[i] = fd.Imports().Get(i).Path()
+	}
+
+	return paths
+}
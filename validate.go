@@ -0,0 +1,276 @@
+package protokit
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
+)
+
+var (
+	// ErrMissingFile indicates that a file to generate isn't included in the request's proto files
+	ErrMissingFile = errors.New("file not found in request")
+
+	// ErrMissingDependency indicates that a file imports a file that isn't included in the request
+	ErrMissingDependency = errors.New("missing dependency")
+
+	// ErrDuplicateSymbol indicates that a file or fully-qualified name is defined more than once
+	ErrDuplicateSymbol = errors.New("duplicate symbol")
+
+	// ErrUnresolvedType indicates that a type reference (field type, extendee, method input/output) doesn't match any
+	// message or enum in the request
+	ErrUnresolvedType = errors.New("unresolved type reference")
+
+	// ErrInvalidSourceInfo indicates that a `SourceCodeInfo` location doesn't match the file's contents
+	ErrInvalidSourceInfo = errors.New("invalid source info")
+)
+
+// A ParseError describes a problem found while validating a `CodeGeneratorRequest`. Use `errors.Is` with one of the
+// `Err*` values to check what kind of problem it is.
+type ParseError struct {
+	// File is the name of the proto file the problem was found in
+	File string
+	// Element identifies the offending element within the file. This is either the fully-qualified name of a
+	// descriptor, the name of a dependency, or a source location path (e.g. `4.0.2.1`). It's empty when the problem
+	// applies to the whole file.
+	Element string
+	// Err describes the problem and wraps one of the `Err*` values
+	Err error
+}
+
+// Error returns the error message in the form `file: element: problem`
+func (e *ParseError) Error() string {
+	if e.Element == "" {
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	}
+
+	return fmt.Sprintf("%s: %s: %v", e.File, e.Element, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// requestValidator collects all problems in a request rather than stopping at the first one.
+type requestValidator struct {
+	files   map[string]*FileDescriptor
+	symbols map[string]string // fully-qualified name => name of the file that defined it
+	errs    []error
+}
+
+func validateRequest(req *pluginpb.CodeGeneratorRequest, files map[string]*FileDescriptor) error {
+	v := &requestValidator{files: files, symbols: make(map[string]string)}
+	seen := make(map[string]bool)
+
+	for _, pf := range req.GetProtoFile() {
+		if seen[pf.GetName()] {
+			v.addError(pf.GetName(), "", fmt.Errorf("%w: file is included more than once", ErrDuplicateSymbol))
+			continue
+		}
+
+		seen[pf.GetName()] = true
+		v.validateDependencies(pf)
+		v.validateSourceInfo(pf)
+
+		file := files[pf.GetName()]
+		v.validateEnums(file, file.GetPackage(), file.GetEnums())
+		v.validateExtensions(file, file.GetPackage(), file.GetExtensions())
+		v.validateMessages(file, file.GetPackage(), file.GetMessages())
+		v.validateServices(file, file.GetServices())
+	}
+
+	for _, name := range req.GetFileToGenerate() {
+		if !seen[name] {
+			v.addError(name, "", ErrMissingFile)
+		}
+	}
+
+	return errors.Join(v.errs...)
+}
+
+func (v *requestValidator) addError(file, element string, err error) {
+	v.errs = append(v.errs, &ParseError{File: file, Element: element, Err: err})
+}
+
+func (v *requestValidator) validateDependencies(pf *descriptorpb.FileDescriptorProto) {
+	for _, dep := range pf.GetDependency() {
+		if _, ok := v.files[dep]; !ok {
+			v.addError(pf.GetName(), dep, ErrMissingDependency)
+		}
+	}
+
+	v.validateDependencyIndexes(pf, "public", pf.GetPublicDependency())
+	v.validateDependencyIndexes(pf, "weak", pf.GetWeakDependency())
+}
+
+func (v *requestValidator) validateDependencyIndexes(
+	pf *descriptorpb.FileDescriptorProto,
+	kind string,
+	indexes []int32,
+) {
+	for _, index := range indexes {
+		if index < 0 || int(index) >= len(pf.GetDependency()) {
+			err := fmt.Errorf("%w: %s dependency index %d is out of range", ErrMissingDependency, kind, index)
+			v.addError(pf.GetName(), "", err)
+		}
+	}
+}
+
+func (v *requestValidator) validateSourceInfo(pf *descriptorpb.FileDescriptorProto) {
+	root := pf.ProtoReflect()
+
+	for _, loc := range pf.GetSourceCodeInfo().GetLocation() {
+		key := sourcePathKey(loc.GetPath())
+
+		if span := loc.GetSpan(); len(span) != 3 && len(span) != 4 {
+			err := fmt.Errorf("%w: span must have 3 or 4 elements, got %d", ErrInvalidSourceInfo, len(span))
+			v.addError(pf.GetName(), key, err)
+		}
+
+		if !isValidSourcePath(root, loc.GetPath()) {
+			err := fmt.Errorf("%w: path doesn't match any element", ErrInvalidSourceInfo)
+			v.addError(pf.GetName(), key, err)
+		}
+	}
+}
+
+// isValidSourcePath reports whether the path points at an element in the given message. See `SourceCodeInfo.Location`
+// in descriptor.proto for details on how paths are built.
+func isValidSourcePath(msg protoreflect.Message, path []int32) bool {
+	for i := 0; i < len(path); i++ {
+		number := protoreflect.FieldNumber(path[i])
+		field := msg.Descriptor().Fields().ByNumber(number)
+		if field == nil {
+			// custom options are extensions, which can't be checked without their declarations
+			return msg.Descriptor().ExtensionRanges().Has(number)
+		}
+
+		if i == len(path)-1 {
+			return true
+		}
+
+		if field.IsList() {
+			i++
+			if path[i] < 0 || int(path[i]) >= msg.Get(field).List().Len() {
+				return false
+			}
+
+			if i == len(path)-1 {
+				return true
+			}
+
+			if field.Message() == nil {
+				return false
+			}
+
+			msg = msg.Get(field).List().Get(int(path[i])).Message()
+			continue
+		}
+
+		if field.Message() == nil {
+			return false
+		}
+
+		msg = msg.Get(field).Message()
+	}
+
+	return true
+}
+
+func (v *requestValidator) declare(file *FileDescriptor, scope, name string) string {
+	fullName := name
+	if scope != "" {
+		fullName = scope + "." + name
+	}
+
+	if prev, ok := v.symbols[fullName]; ok {
+		err := fmt.Errorf("%w: already defined in %s", ErrDuplicateSymbol, prev)
+		v.addError(file.GetName(), fullName, err)
+		return fullName
+	}
+
+	v.symbols[fullName] = file.GetName()
+	return fullName
+}
+
+func (v *requestValidator) validateEnums(file *FileDescriptor, scope string, enums []*EnumDescriptor) {
+	for _, e := range enums {
+		v.declare(file, scope, e.GetName())
+
+		// enum values are siblings of their enum (C++ scoping rules)
+		for _, val := range e.GetValues() {
+			v.declare(file, scope, val.GetName())
+		}
+	}
+}
+
+func (v *requestValidator) validateExtensions(file *FileDescriptor, scope string, exts []*ExtensionDescriptor) {
+	for _, ext := range exts {
+		name := v.declare(file, scope, ext.GetName())
+
+		if ext.GetExtendedMessage() == nil {
+			v.addError(file.GetName(), name, fmt.Errorf("%w: %q", ErrUnresolvedType, ext.GetExtendee()))
+		}
+
+		v.validateTypeName(file, name, ext.FieldDescriptorProto, ext.GetMessageType(), ext.GetEnumType())
+	}
+}
+
+func (v *requestValidator) validateMessages(file *FileDescriptor, scope string, msgs []*Descriptor) {
+	for _, m := range msgs {
+		name := v.declare(file, scope, m.GetName())
+
+		for _, f := range m.GetMessageFields() {
+			field := v.declare(file, name, f.GetName())
+			v.validateTypeName(file, field, f.FieldDescriptorProto, f.GetMessageType(), f.GetEnumType())
+		}
+
+		for _, o := range m.GetOneofs() {
+			v.declare(file, name, o.GetName())
+		}
+
+		v.validateEnums(file, name, m.GetEnums())
+		v.validateExtensions(file, name, m.GetExtensions())
+		v.validateMessages(file, name, m.GetMessages())
+	}
+}
+
+func (v *requestValidator) validateTypeName(
+	file *FileDescriptor,
+	element string,
+	fd *descriptorpb.FieldDescriptorProto,
+	msg *Descriptor,
+	enum *EnumDescriptor,
+) {
+	switch {
+	case fd.GetTypeName() != "" && msg == nil && enum == nil:
+		v.addError(file.GetName(), element, fmt.Errorf("%w: %q", ErrUnresolvedType, fd.GetTypeName()))
+	case fd.GetTypeName() == "" && isNamedType(fd.GetType()):
+		v.addError(file.GetName(), element, fmt.Errorf("%w: missing type name for %s", ErrUnresolvedType, fd.GetType()))
+	}
+}
+
+func (v *requestValidator) validateServices(file *FileDescriptor, svcs []*ServiceDescriptor) {
+	for _, svc := range svcs {
+		name := v.declare(file, file.GetPackage(), svc.GetName())
+
+		for _, m := range svc.GetMethods() {
+			method := v.declare(file, name, m.GetName())
+
+			if m.GetInput() == nil {
+				v.addError(file.GetName(), method, fmt.Errorf("%w: %q", ErrUnresolvedType, m.GetInputType()))
+			}
+
+			if m.GetOutput() == nil {
+				v.addError(file.GetName(), method, fmt.Errorf("%w: %q", ErrUnresolvedType, m.GetOutputType()))
+			}
+		}
+	}
+}
+
+func isNamedType(t descriptorpb.FieldDescriptorProto_Type) bool {
+	return t == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE ||
+		t == descriptorpb.FieldDescriptorProto_TYPE_GROUP ||
+		t == descriptorpb.FieldDescriptorProto_TYPE_ENUM
+}
@@ -0,0 +1,142 @@
+package protokit_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pseudomuto/protokit"
+	"github.com/pseudomuto/protokit/utils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
+)
+
+func fixtureRequest(t *testing.T, filesToGen ...string) *pluginpb.CodeGeneratorRequest {
+	t.Helper()
+
+	set, err := utils.LoadDescriptorSet("fixtures", "fileset.pb")
+	require.NoError(t, err)
+
+	return utils.CreateGenRequest(set, filesToGen...)
+}
+
+func parseErrors(t *testing.T, err error) []*protokit.ParseError {
+	t.Helper()
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	require.True(t, ok, "expected joined errors, got %T", err)
+
+	errs := make([]*protokit.ParseError, 0)
+	for _, e := range joined.Unwrap() {
+		var pe *protokit.ParseError
+		require.True(t, errors.As(e, &pe))
+		errs = append(errs, pe)
+	}
+
+	return errs
+}
+
+func TestParseCodeGenRequestE(t *testing.T) {
+	t.Parallel()
+
+	req := fixtureRequest(t, "booking.proto", "todo.proto")
+
+	files, err := protokit.ParseCodeGenRequestE(req)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.Equal(t, "booking.proto", files[0].GetName())
+	require.Len(t, files[1].GetImports(), 2)
+}
+
+func TestParseCodeGenRequestEMissingFiles(t *testing.T) {
+	t.Parallel()
+
+	req := fixtureRequest(t, "todo.proto")
+	req.FileToGenerate = append(req.FileToGenerate, "whodis.proto")
+
+	// drop todo_import.proto, which is publicly imported by todo.proto
+	protos := make([]*descriptorpb.FileDescriptorProto, 0)
+	for _, pf := range req.GetProtoFile() {
+		if pf.GetName() != "todo_import.proto" {
+			protos = append(protos, pf)
+		}
+	}
+	req.ProtoFile = protos
+
+	// the non-validating parser just skips what it can't find
+	require.NotPanics(t, func() { protokit.ParseCodeGenRequest(req) })
+
+	files, err := protokit.ParseCodeGenRequestE(req)
+	require.Nil(t, files)
+	require.True(t, errors.Is(err, protokit.ErrMissingDependency))
+	require.True(t, errors.Is(err, protokit.ErrMissingFile))
+	require.True(t, errors.Is(err, protokit.ErrUnresolvedType))
+
+	errs := parseErrors(t, err)
+	require.Equal(t, "todo.proto: todo_import.proto: missing dependency", errs[0].Error())
+	require.Equal(t, "todo.proto", errs[1].File)
+	require.Equal(t, "com.pseudomuto.protokit.v1.Item.details", errs[1].Element)
+	require.EqualError(t, errs[1], `todo.proto: com.pseudomuto.protokit.v1.Item.details: `+
+		`unresolved type reference: ".com.pseudomuto.protokit.v1.ListItemDetails"`)
+	require.Equal(t, "whodis.proto: file not found in request", errs[2].Error())
+}
+
+func TestParseCodeGenRequestEDuplicates(t *testing.T) {
+	t.Parallel()
+
+	dup := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("dup.proto"),
+		Package: proto.String("com.pseudomuto.protokit.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Booking")},
+		},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name:  proto.String("Other"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{{Name: proto.String("IMMEDIATE"), Number: proto.Int32(0)}},
+		}},
+	}
+
+	req := fixtureRequest(t, "booking.proto")
+	req.ProtoFile = append(req.ProtoFile, dup, dup)
+
+	_, err := protokit.ParseCodeGenRequestE(req)
+	require.True(t, errors.Is(err, protokit.ErrDuplicateSymbol))
+
+	errs := parseErrors(t, err)
+	require.Len(t, errs, 3)
+	require.Equal(t, "com.pseudomuto.protokit.v1.IMMEDIATE", errs[0].Element)
+	require.Equal(t, "dup.proto: com.pseudomuto.protokit.v1.Booking: duplicate symbol: already defined in booking.proto",
+		errs[1].Error())
+	require.Equal(t, "dup.proto: duplicate symbol: file is included more than once", errs[2].Error())
+}
+
+func TestParseCodeGenRequestEInvalidSourceInfo(t *testing.T) {
+	t.Parallel()
+
+	req := fixtureRequest(t, "booking.proto")
+
+	for i, pf := range req.GetProtoFile() {
+		if pf.GetName() != "booking.proto" {
+			continue
+		}
+
+		booking := proto.CloneOf(pf)
+		booking.SourceCodeInfo.Location = append(booking.SourceCodeInfo.Location,
+			&descriptorpb.SourceCodeInfo_Location{Path: []int32{4, 99}, Span: []int32{1, 2, 3}},
+			&descriptorpb.SourceCodeInfo_Location{Path: []int32{4, 0, 2, 0}, Span: []int32{1}},
+		)
+		req.ProtoFile[i] = booking
+	}
+
+	_, err := protokit.ParseCodeGenRequestE(req)
+	require.True(t, errors.Is(err, protokit.ErrInvalidSourceInfo))
+	require.False(t, errors.Is(err, protokit.ErrDuplicateSymbol))
+
+	errs := parseErrors(t, err)
+	require.Len(t, errs, 2)
+	require.Equal(t, "booking.proto: 4.99: invalid source info: path doesn't match any element", errs[0].Error())
+	require.Equal(t, "booking.proto: 4.0.2.0: invalid source info: span must have 3 or 4 elements, got 1",
+		errs[1].Error())
+}
@@ -0,0 +1,231 @@
+package protokit
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrSkipChildren can be returned by a `Visitor` callback to skip the children of the element being visited (e.g.
+	// the fields and nested types of a message). It's ignored for elements without children.
+	ErrSkipChildren = errors.New("skip children")
+
+	// ErrSkipAll can be returned by any callback to stop walking. `Walk` returns `nil` in this case.
+	ErrSkipAll = errors.New("skip all")
+)
+
+// A Visitor is called by `Walk` for each element in a file. Elements are visited before their children.
+//
+// The context passed to each callback includes the ancestors of the element, which can be retrieved using the context
+// helpers (e.g. `FileDescriptorFromContext`, `DescriptorFromContext` for the enclosing message,
+// `EnumDescriptorFromContext` for the enum of a value or `ServiceDescriptorFromContext` for the service of a method).
+//
+// Callbacks can return `ErrSkipChildren` to skip the children of an element or `ErrSkipAll` to stop walking. Any other
+// error stops the walk and is returned by `Walk`. Embed `BaseVisitor` to only implement the callbacks you need.
+type Visitor interface {
+	VisitMessage(ctx context.Context, msg *Descriptor) error
+	VisitField(ctx context.Context, field *FieldDescriptor) error
+	VisitEnum(ctx context.Context, enum *EnumDescriptor) error
+	VisitEnumValue(ctx context.Context, value *EnumValueDescriptor) error
+	VisitService(ctx context.Context, svc *ServiceDescriptor) error
+	VisitMethod(ctx context.Context, method *MethodDescriptor) error
+	VisitExtension(ctx context.Context, ext *ExtensionDescriptor) error
+}
+
+// A PostVisitor is a `Visitor` that is also called after the children of messages, enums and services have been
+// visited. The `Leave` callbacks aren't called when the corresponding `Visit` callback returned an error (including
+// `ErrSkipChildren`).
+type PostVisitor interface {
+	Visitor
+
+	LeaveMessage(ctx context.Context, msg *Descriptor) error
+	LeaveEnum(ctx context.Context, enum *EnumDescriptor) error
+	LeaveService(ctx context.Context, svc *ServiceDescriptor) error
+}
+
+// BaseVisitor implements `PostVisitor` by doing nothing. It's meant to be embedded in visitors that only need some of
+// the callbacks.
+type BaseVisitor struct{}
+
+// VisitMessage implements `Visitor`
+func (BaseVisitor) VisitMessage(context.Context, *Descriptor) error { return nil }
+
+// VisitField implements `Visitor`
+func (BaseVisitor) VisitField(context.Context, *FieldDescriptor) error { return nil }
+
+// VisitEnum implements `Visitor`
+func (BaseVisitor) VisitEnum(context.Context, *EnumDescriptor) error { return nil }
+
+// VisitEnumValue implements `Visitor`
+func (BaseVisitor) VisitEnumValue(context.Context, *EnumValueDescriptor) error { return nil }
+
+// VisitService implements `Visitor`
+func (BaseVisitor) VisitService(context.Context, *ServiceDescriptor) error { return nil }
+
+// VisitMethod implements `Visitor`
+func (BaseVisitor) VisitMethod(context.Context, *MethodDescriptor) error { return nil }
+
+// VisitExtension implements `Visitor`
+func (BaseVisitor) VisitExtension(context.Context, *ExtensionDescriptor) error { return nil }
+
+// LeaveMessage implements `PostVisitor`
+func (BaseVisitor) LeaveMessage(context.Context, *Descriptor) error { return nil }
+
+// LeaveEnum implements `PostVisitor`
+func (BaseVisitor) LeaveEnum(context.Context, *EnumDescriptor) error { return nil }
+
+// LeaveService implements `PostVisitor`
+func (BaseVisitor) LeaveService(context.Context, *ServiceDescriptor) error { return nil }
+
+// Walk visits every element of the file depth-first. Elements are visited in the order of the fields that contain them
+// in descriptor.proto, i.e. messages, enums, services and extensions for files, and fields, nested messages, enums and
+// extensions for messages. Map entry messages are visited like any other nested message.
+//
+// If `v` implements `PostVisitor`, the `Leave` callbacks are called as well.
+func Walk(file *FileDescriptor, v Visitor) error {
+	return WalkContext(context.Background(), file, v)
+}
+
+// WalkContext is like `Walk`, but uses the given context as the parent of the contexts passed to the visitor
+func WalkContext(ctx context.Context, file *FileDescriptor, v Visitor) error {
+	w := &walker{visitor: v}
+	w.post, _ = v.(PostVisitor)
+
+	err := w.walkFile(ContextWithFileDescriptor(ctx, file), file)
+	if errors.Is(err, ErrSkipAll) {
+		return nil
+	}
+
+	return err
+}
+
+type walker struct {
+	visitor Visitor
+	post    PostVisitor
+}
+
+func (w *walker) walkFile(ctx context.Context, file *FileDescriptor) error {
+	if err := w.walkMessages(ctx, file.GetMessages()); err != nil {
+		return err
+	}
+
+	if err := w.walkEnums(ctx, file.GetEnums()); err != nil {
+		return err
+	}
+
+	for _, svc := range file.GetServices() {
+		if err := w.walkService(ctx, svc); err != nil {
+			return err
+		}
+	}
+
+	return w.walkExtensions(ctx, file.GetExtensions())
+}
+
+func (w *walker) walkMessages(ctx context.Context, msgs []*Descriptor) error {
+	for _, msg := range msgs {
+		if err := w.walkMessage(ctx, msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *walker) walkMessage(ctx context.Context, msg *Descriptor) error {
+	if err := w.visitor.VisitMessage(ctx, msg); err != nil {
+		return skipped(err)
+	}
+
+	msgCtx := ContextWithDescriptor(ctx, msg)
+	for _, f := range msg.GetMessageFields() {
+		if err := skipped(w.visitor.VisitField(msgCtx, f)); err != nil {
+			return err
+		}
+	}
+
+	if err := w.walkMessages(msgCtx, msg.GetMessages()); err != nil {
+		return err
+	}
+
+	if err := w.walkEnums(msgCtx, msg.GetEnums()); err != nil {
+		return err
+	}
+
+	if err := w.walkExtensions(msgCtx, msg.GetExtensions()); err != nil {
+		return err
+	}
+
+	if w.post != nil {
+		return skipped(w.post.LeaveMessage(ctx, msg))
+	}
+
+	return nil
+}
+
+func (w *walker) walkEnums(ctx context.Context, enums []*EnumDescriptor) error {
+	for _, enum := range enums {
+		if err := w.walkEnum(ctx, enum); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *walker) walkEnum(ctx context.Context, enum *EnumDescriptor) error {
+	if err := w.visitor.VisitEnum(ctx, enum); err != nil {
+		return skipped(err)
+	}
+
+	enumCtx := ContextWithEnumDescriptor(ctx, enum)
+	for _, v := range enum.GetValues() {
+		if err := skipped(w.visitor.VisitEnumValue(enumCtx, v)); err != nil {
+			return err
+		}
+	}
+
+	if w.post != nil {
+		return skipped(w.post.LeaveEnum(ctx, enum))
+	}
+
+	return nil
+}
+
+func (w *walker) walkService(ctx context.Context, svc *ServiceDescriptor) error {
+	if err := w.visitor.VisitService(ctx, svc); err != nil {
+		return skipped(err)
+	}
+
+	svcCtx := ContextWithServiceDescriptor(ctx, svc)
+	for _, m := range svc.GetMethods() {
+		if err := skipped(w.visitor.VisitMethod(svcCtx, m)); err != nil {
+			return err
+		}
+	}
+
+	if w.post != nil {
+		return skipped(w.post.LeaveService(ctx, svc))
+	}
+
+	return nil
+}
+
+func (w *walker) walkExtensions(ctx context.Context, exts []*ExtensionDescriptor) error {
+	for _, ext := range exts {
+		if err := skipped(w.visitor.VisitExtension(ctx, ext)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// skipped returns `nil` for `ErrSkipChildren` (the children have been skipped at this point) and err otherwise
+func skipped(err error) error {
+	if errors.Is(err, ErrSkipChildren) {
+		return nil
+	}
+
+	return err
+}
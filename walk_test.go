@@ -0,0 +1,205 @@
+package protokit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pseudomuto/protokit"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder records the elements it visits along with the ancestors found in the context
+type recorder struct {
+	protokit.BaseVisitor
+
+	events []string
+	skip   string
+	stop   string
+	err    error
+}
+
+func (r *recorder) record(ctx context.Context, kind, name string) error {
+	if parent, ok := protokit.DescriptorFromContext(ctx); ok {
+		name = parent.GetName() + "/" + name
+	}
+
+	r.events = append(r.events, kind+":"+name)
+
+	switch name {
+	case r.skip:
+		return protokit.ErrSkipChildren
+	case r.stop:
+		if r.err != nil {
+			return r.err
+		}
+
+		return protokit.ErrSkipAll
+	default:
+		return nil
+	}
+}
+
+func (r *recorder) VisitMessage(ctx context.Context, msg *protokit.Descriptor) error {
+	return r.record(ctx, "message", msg.GetName())
+}
+
+func (r *recorder) VisitField(ctx context.Context, field *protokit.FieldDescriptor) error {
+	return r.record(ctx, "field", field.GetName())
+}
+
+func (r *recorder) VisitEnum(ctx context.Context, enum *protokit.EnumDescriptor) error {
+	return r.record(ctx, "enum", enum.GetName())
+}
+
+func (r *recorder) VisitEnumValue(ctx context.Context, value *protokit.EnumValueDescriptor) error {
+	enum, _ := protokit.EnumDescriptorFromContext(ctx)
+	return r.record(ctx, "value", enum.GetName()+"."+value.GetName())
+}
+
+func (r *recorder) VisitService(ctx context.Context, svc *protokit.ServiceDescriptor) error {
+	return r.record(ctx, "service", svc.GetName())
+}
+
+func (r *recorder) VisitMethod(ctx context.Context, method *protokit.MethodDescriptor) error {
+	svc, _ := protokit.ServiceDescriptorFromContext(ctx)
+	return r.record(ctx, "method", svc.GetName()+"."+method.GetName())
+}
+
+func (r *recorder) VisitExtension(ctx context.Context, ext *protokit.ExtensionDescriptor) error {
+	file, _ := protokit.FileDescriptorFromContext(ctx)
+	return r.record(ctx, "extension", file.GetName()+":"+ext.GetName())
+}
+
+func (r *recorder) LeaveMessage(ctx context.Context, msg *protokit.Descriptor) error {
+	return r.record(ctx, "leave", msg.GetName())
+}
+
+func (r *recorder) LeaveEnum(ctx context.Context, enum *protokit.EnumDescriptor) error {
+	return r.record(ctx, "leave", enum.GetName())
+}
+
+func (r *recorder) LeaveService(ctx context.Context, svc *protokit.ServiceDescriptor) error {
+	return r.record(ctx, "leave", svc.GetName())
+}
+
+func TestWalk(t *testing.T) {
+	t.Parallel()
+
+	file := protokit.ParseCodeGenRequest(fixtureRequest(t, "booking.proto"))[0]
+
+	r := new(recorder)
+	require.NoError(t, protokit.Walk(file, r))
+	require.Equal(t, []string{
+		"message:BookingStatus",
+		"field:BookingStatus/id",
+		"field:BookingStatus/description",
+		"field:BookingStatus/status_code",
+		"enum:BookingStatus/StatusCode",
+		"value:BookingStatus/StatusCode.OK",
+		"value:BookingStatus/StatusCode.BAD_REQUEST",
+		"leave:BookingStatus/StatusCode",
+		"leave:BookingStatus",
+		"message:Booking",
+		"field:Booking/vehicle_id",
+		"field:Booking/customer_id",
+		"field:Booking/status",
+		"field:Booking/confirmation_sent",
+		"field:Booking/payment_received",
+		"field:Booking/reference_num",
+		"field:Booking/reference_tag",
+		"extension:Booking/booking.proto:optional_field_1",
+		"leave:Booking",
+		"enum:BookingType",
+		"value:BookingType.IMMEDIATE",
+		"value:BookingType.FUTURE",
+		"leave:BookingType",
+		"service:BookingService",
+		"method:BookingService.BookVehicle",
+		"leave:BookingService",
+		"extension:booking.proto:country",
+	}, r.events)
+}
+
+func TestWalkSkipChildren(t *testing.T) {
+	t.Parallel()
+
+	file := protokit.ParseCodeGenRequest(fixtureRequest(t, "booking.proto"))[0]
+
+	r := &recorder{skip: "Booking"}
+	require.NoError(t, protokit.Walk(file, r))
+	require.Contains(t, r.events, "message:Booking")
+	require.NotContains(t, r.events, "field:Booking/vehicle_id")
+	require.NotContains(t, r.events, "leave:Booking")
+	require.Contains(t, r.events, "enum:BookingType")
+
+	// skipping an element without children doesn't affect its siblings
+	r = &recorder{skip: "BookingStatus/id"}
+	require.NoError(t, protokit.Walk(file, r))
+	require.Contains(t, r.events, "field:BookingStatus/description")
+}
+
+func TestWalkStop(t *testing.T) {
+	t.Parallel()
+
+	file := protokit.ParseCodeGenRequest(fixtureRequest(t, "booking.proto"))[0]
+
+	r := &recorder{stop: "BookingStatus/StatusCode.OK"}
+	require.NoError(t, protokit.Walk(file, r))
+	require.Equal(t, "value:BookingStatus/StatusCode.OK", r.events[len(r.events)-1])
+
+	// other errors are returned as is
+	boom := errors.New("boom")
+	r = &recorder{stop: "BookingService.BookVehicle", err: boom}
+	require.Equal(t, boom, protokit.Walk(file, r))
+	require.Equal(t, "method:BookingService.BookVehicle", r.events[len(r.events)-1])
+}
+
+func TestWalkContext(t *testing.T) {
+	t.Parallel()
+
+	type key struct{}
+
+	file := protokit.ParseCodeGenRequest(fixtureRequest(t, "booking.proto"))[0]
+	ctx := context.WithValue(context.Background(), key{}, "value")
+
+	v := &contextVisitor{check: func(ctx context.Context) {
+		require.Equal(t, "value", ctx.Value(key{}))
+	}}
+	require.NoError(t, protokit.WalkContext(ctx, file, v))
+	require.Equal(t, 3, v.calls)
+}
+
+// contextVisitor only implements `Visitor` (without the `Leave` callbacks)
+type contextVisitor struct {
+	calls int
+	check func(context.Context)
+}
+
+func (v *contextVisitor) VisitMessage(ctx context.Context, _ *protokit.Descriptor) error {
+	v.calls++
+	v.check(ctx)
+	return nil
+}
+
+func (v *contextVisitor) VisitField(context.Context, *protokit.FieldDescriptor) error {
+	return protokit.ErrSkipChildren
+}
+
+func (v *contextVisitor) VisitEnum(context.Context, *protokit.EnumDescriptor) error { return nil }
+
+func (v *contextVisitor) VisitEnumValue(context.Context, *protokit.EnumValueDescriptor) error {
+	return nil
+}
+
+func (v *contextVisitor) VisitService(ctx context.Context, _ *protokit.ServiceDescriptor) error {
+	v.calls++
+	v.check(ctx)
+	return nil
+}
+
+func (v *contextVisitor) VisitMethod(context.Context, *protokit.MethodDescriptor) error { return nil }
+
+func (v *contextVisitor) VisitExtension(context.Context, *protokit.ExtensionDescriptor) error {
+	return nil
+}